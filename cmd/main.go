@@ -1,7 +1,15 @@
-package cmd
+package main
 
 import (
 	"fmt"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/cron"
+	"grampanchayat/database"
+	"grampanchayat/handler"
+	"grampanchayat/jwtkeys"
+	"grampanchayat/server"
+	"grampanchayat/sms"
+	"grampanchayat/storage"
 	"os"
 )
 
@@ -18,6 +26,21 @@ func main() {
 		return
 	}
 	fmt.Println("connected")
+	handler.SigningKeys, err = jwtkeys.LoadFromEnv()
+	if err != nil {
+		logrus.Printf("could not load jwt signing keys:%v", err)
+		return
+	}
+	handler.OTPSender, err = sms.NewFromEnv()
+	if err != nil {
+		logrus.Printf("could not setup sms provider:%v", err)
+		return
+	}
+	handler.Attachments, err = storage.NewFromEnv()
+	if err != nil {
+		logrus.Printf("could not setup file storage:%v", err)
+		return
+	}
 	srv := server.SetupRoutes()
 	go cron.RunCronJob()
 	err = srv.Run(fmt.Sprintf(":%s", serverPort))
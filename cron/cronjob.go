@@ -1,14 +1,22 @@
 package cron
 
 import (
+	"database/sql"
+	"fmt"
 	"github.com/go-co-op/gocron"
+	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
 	"grampanchayat/database/helper"
+	"grampanchayat/utilities"
 	"math"
 	"math/rand"
 	"time"
 )
 
+// escalationInterval is how long an overdue task stays at one level before it goes up the next
+const escalationInterval = 3 * 24 * time.Hour
+
 func RunCronJob() {
 	s := gocron.NewScheduler(time.Local)
 	_, err := s.Every(1).Day().At("02:00").Do(func() {
@@ -34,5 +42,70 @@ func RunCronJob() {
 		return
 	}
 
+	_, err = s.Every(1).Day().At("09:00").Do(escalateOverdueTasks)
+	if err != nil {
+		return
+	}
+
 	s.StartBlocking()
 }
+
+// escalateOverdueTasks moves every overdue task to the level its delay calls for and notifies the official of that level
+func escalateOverdueTasks() {
+	tasks, err := helper.GetOpenTasksWithSLA()
+	if err != nil {
+		logrus.Printf("escalateOverdueTasks: unable to get open tasks. %v", err)
+		return
+	}
+
+	holidays, err := helper.GetHolidayCalendar()
+	if err != nil {
+		logrus.Printf("escalateOverdueTasks: unable to get holidays. %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, task := range tasks {
+		dueDate := utilities.DueDate(task.SLAStartedAt, task.SLADays, task.SLAWorking, holidays)
+		if now.Before(dueDate) {
+			continue
+		}
+
+		level := utilities.EscalationSachiv + int(now.Sub(dueDate)/escalationInterval)
+		if level > utilities.EscalationDistrict {
+			level = utilities.EscalationDistrict
+		}
+		if level <= task.EscalationLevel {
+			continue
+		}
+
+		recipientID, err := helper.GetEscalationRecipient(task.TaskID, level)
+		if err != nil && err != sql.ErrNoRows {
+			logrus.Printf("escalateOverdueTasks: unable to get recipient of task %d. %v", task.TaskID, err)
+			continue
+		}
+
+		message := fmt.Sprintf("%s for %s is overdue since %s", task.TaskType, task.DeathName, dueDate.Format("02-01-2006"))
+
+		err = database.Tx(func(tx *sqlx.Tx) error {
+			err := helper.EscalateTask(task.TaskID, level, tx)
+			if err != nil {
+				return err
+			}
+			// nobody holds the post, the level is still recorded so the next run moves on
+			if recipientID != 0 {
+				err = helper.AddNotification(recipientID, message, task.TaskID, tx)
+				if err != nil {
+					return err
+				}
+			}
+			if level == utilities.EscalationSachiv && task.AssignedTo.Valid && int(task.AssignedTo.Int64) != recipientID {
+				return helper.AddNotification(int(task.AssignedTo.Int64), message, task.TaskID, tx)
+			}
+			return nil
+		})
+		if err != nil {
+			logrus.Printf("escalateOverdueTasks: unable to escalate task %d. %v", task.TaskID, err)
+		}
+	}
+}
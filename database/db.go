@@ -5,6 +5,7 @@ import (
 	// for importing migrations
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 
+	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
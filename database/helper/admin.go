@@ -18,60 +18,146 @@ import (
 	"time"
 )
 
-func CreateSession(claims *models.Claims) error {
+func CreateSession(userID int, refreshTokenHash string, expiresAt time.Time) (uuid.UUID, error) {
 	// language=SQL
-	SQL := `INSERT INTO sessions(user_id, expires_at)
-            VALUES ($1, $2)`
+	SQL := `INSERT INTO sessions(user_id, refresh_token_hash, expires_at)
+            VALUES ($1, $2, $3)
+            RETURNING id`
 
-	_, err := database.GramPanchayatDB.Exec(SQL, claims.ID, time.Now().Add(60*time.Hour))
+	var sessionID uuid.UUID
+
+	err := database.GramPanchayatDB.Get(&sessionID, SQL, userID, refreshTokenHash, expiresAt)
 	if err != nil {
 		logrus.Printf("CreateSession: cannot create session:%v", err)
-		return err
+		return sessionID, err
 	}
-	return nil
+	return sessionID, nil
 }
 
-func CheckSession(userID int) (uuid.UUID, error) {
+func CheckSession(sessionID uuid.UUID, userID int) error {
 	// language=SQL
 	SQL := `SELECT id
             FROM   sessions
-            WHERE  expires_at > now()
-            AND    user_id = $1
-            ORDER BY created_at desc LIMIT 1`
+            WHERE  id = $1
+            AND    user_id = $2
+            AND    expires_at > now()
+            AND    archived_at IS NULL`
 
-	var sessionID uuid.UUID
+	var id uuid.UUID
 
-	err := database.GramPanchayatDB.Get(&sessionID, SQL, userID)
+	err := database.GramPanchayatDB.Get(&id, SQL, sessionID, userID)
 	if err != nil {
 		logrus.Printf("CheckSession: cannot get session id:%v", err)
-		return sessionID, err
+		return err
 	}
-	return sessionID, nil
+	return nil
 }
 
-func GetDisplayTypes(role string) ([]string, error) {
+// RotateRefreshToken swaps the refresh token hash of a live session and extends it,
+// returns false when the presented hash is not the current one, the session is then over or the token is wrong or already used
+func RotateRefreshToken(sessionID uuid.UUID, oldHash, newHash string, expiresAt time.Time) (models.UserCredentials, bool, error) {
+	// language=SQL
+	SQL := `UPDATE sessions
+            SET    previous_refresh_token_hash = sessions.refresh_token_hash,
+                   refresh_token_hash = $3,
+                   expires_at = $4,
+                   updated_at = now()
+            FROM   users JOIN roles r on r.id = users.roles_id
+            WHERE  sessions.id = $1
+            AND    sessions.refresh_token_hash = $2
+            AND    sessions.expires_at > now()
+            AND    sessions.archived_at IS NULL
+            AND    users.id = sessions.user_id
+            AND    users.archived_at IS NULL
+            RETURNING users.id, r.role`
 
-	var SQL string
-	if role == utilities.Sahayak || role == utilities.Sachiv || role == utilities.LekhPal {
-		// language=SQL
-		SQL = `SELECT name
-            FROM   task_types`
-		types := make([]string, 0)
+	var userCredentials models.UserCredentials
 
-		err := database.GramPanchayatDB.Select(&types, SQL)
-		return types, err
-	} else {
-		// language=SQL
-		SQL = `SELECT name
-            FROM   task_types
-            join task_role tr on task_types.id = tr.task_type_id
-            join roles r on tr.role_id = r.id
-            where r.role = $1`
-		types := make([]string, 0)
+	err := database.GramPanchayatDB.Get(&userCredentials, SQL, sessionID, oldHash, newHash, expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return userCredentials, false, nil
+		}
+		logrus.Printf("RotateRefreshToken: cannot rotate refresh token:%v", err)
+		return userCredentials, false, err
+	}
+	return userCredentials, true, nil
+}
+
+func ArchiveSession(sessionID uuid.UUID) error {
+	// language=SQL
+	SQL := `UPDATE sessions
+            SET    archived_at = now()
+            WHERE  id = $1
+            AND    archived_at IS NULL`
 
-		err := database.GramPanchayatDB.Select(&types, SQL, role)
-		return types, err
+	_, err := database.GramPanchayatDB.Exec(SQL, sessionID)
+	if err != nil {
+		logrus.Printf("ArchiveSession: cannot archive session:%v", err)
+		return err
 	}
+	return nil
+}
+
+// ArchiveReplayedSession ends the session when the presented hash is the one its refresh token was rotated away from,
+// returns false when it is not, a wrong secret alone does not end anybody's session
+func ArchiveReplayedSession(sessionID uuid.UUID, presentedHash string) (bool, error) {
+	// language=SQL
+	SQL := `UPDATE sessions
+            SET    archived_at = now()
+            WHERE  id = $1
+            AND    previous_refresh_token_hash = $2
+            AND    archived_at IS NULL`
+
+	result, err := database.GramPanchayatDB.Exec(SQL, sessionID, presentedHash)
+	if err != nil {
+		logrus.Printf("ArchiveReplayedSession: cannot archive session:%v", err)
+		return false, err
+	}
+	archived, err := result.RowsAffected()
+	if err != nil {
+		logrus.Printf("ArchiveReplayedSession: cannot count archived sessions:%v", err)
+		return false, err
+	}
+	return archived > 0, nil
+}
+
+// ArchiveUserSessions logs the user out of every device, returns the number of sessions ended
+func ArchiveUserSessions(userID int, tx *sqlx.Tx) (int64, error) {
+	// language=SQL
+	SQL := `UPDATE sessions
+            SET    archived_at = now()
+            WHERE  user_id = $1
+            AND    archived_at IS NULL`
+
+	result, err := tx.Exec(SQL, userID)
+	if err != nil {
+		logrus.Printf("ArchiveUserSessions: cannot archive sessions:%v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetDisplayTypes returns the task types the role can see, every type if the role may view all tasks
+func GetDisplayTypes(role string) ([]string, error) {
+	// language=SQL
+	SQL := `SELECT name
+            FROM   task_types
+            WHERE  EXISTS(SELECT 1
+                          FROM   role_permissions rp
+                                 JOIN roles r on r.id = rp.role_id
+                                 JOIN permissions p on p.id = rp.permission_id
+                          WHERE  r.role = $1
+                          AND    p.name = $2
+                          AND    rp.archived_at IS NULL)
+            OR     id IN (SELECT tr.task_type_id
+                          FROM   task_role tr
+                                 JOIN roles r on tr.role_id = r.id
+                          WHERE  r.role = $1)`
+	types := make([]string, 0)
+
+	err := database.GramPanchayatDB.Select(&types, SQL, role, utilities.PermissionTaskViewAll)
+	return types, err
 }
 
 func GetActionableTaskTypes(role string) ([]string, error) {
@@ -100,16 +186,18 @@ func AddOtp(phone, otp string) error {
 	return nil
 }
 
-func FetchOTP(phone string) (string, error) {
+func FetchOTP(phone string) (models.OTPDetails, error) {
 	// language=SQL
-	SQL := `SELECT otp
+	SQL := `SELECT id,
+                   otp,
+                   failed_attempts
             FROM   otp
             WHERE phone_no= $1
             AND   expiring_time > now() 
             AND   archived_at IS NULL 
             ORDER BY expiring_time desc LIMIT 1`
 
-	var otp string
+	var otp models.OTPDetails
 
 	err := database.GramPanchayatDB.Get(&otp, SQL, phone)
 	if err != nil {
@@ -119,18 +207,94 @@ func FetchOTP(phone string) (string, error) {
 	return otp, nil
 }
 
-func AddRole(roleDetails models.RoleDetails) error {
+// GetOTPStats returns the send and failure counters used for resend throttling and lockout of a phone number
+func GetOTPStats(phone string, lockoutWindow time.Duration) (models.OTPStats, error) {
+	// language=SQL
+	SQL := `SELECT max(created_at)                                                  as last_sent_at,
+                   count(*) filter ( where created_at > now() - '1 hour'::interval ) as sent_last_hour,
+                   coalesce(sum(failed_attempts) filter ( where created_at > now() - $2::interval ), 0) as failed_attempts
+            FROM   otp
+            WHERE  phone_no = $1
+            AND    created_at > now() - greatest('1 hour'::interval, $2::interval)`
+
+	var stats models.OTPStats
+
+	err := database.GramPanchayatDB.Get(&stats, SQL, phone, fmt.Sprintf("%d seconds", int(lockoutWindow.Seconds())))
+	if err != nil {
+		logrus.Printf("GetOTPStats: cannot get otp stats:%v", err)
+		return stats, err
+	}
+	return stats, nil
+}
+
+// IncrementOTPAttempts records a wrong guess, the otp is archived once it reaches maxAttempts
+func IncrementOTPAttempts(otpID, maxAttempts int) error {
+	// language=SQL
+	SQL := `UPDATE otp
+            SET    failed_attempts = failed_attempts + 1,
+                   archived_at = CASE WHEN failed_attempts + 1 >= $2 THEN now() END
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	_, err := database.GramPanchayatDB.Exec(SQL, otpID, maxAttempts)
+	if err != nil {
+		logrus.Printf("IncrementOTPAttempts: cannot update otp attempts:%v", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeOTP archives the matched otp along with every other live otp of the phone,
+// returns false if the otp was already consumed by a concurrent login
+func ConsumeOTP(otpID int, phone string) (bool, error) {
+	consumed := false
+	txErr := database.Tx(func(tx *sqlx.Tx) error {
+		// language=SQL
+		SQL := `UPDATE otp
+                SET    archived_at = now()
+                WHERE  id = $1
+                AND    archived_at IS NULL`
+
+		result, err := tx.Exec(SQL, otpID)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		consumed = rows == 1
+
+		// language=SQL
+		SQL = `UPDATE otp
+               SET    archived_at = now()
+               WHERE  phone_no = $1
+               AND    archived_at IS NULL`
+
+		_, err = tx.Exec(SQL, phone)
+		return err
+	})
+	if txErr != nil {
+		logrus.Printf("ConsumeOTP: cannot archive otp:%v", txErr)
+		return false, txErr
+	}
+	return consumed, nil
+}
+
+func AddRole(roleDetails models.RoleDetails, tx *sqlx.Tx) (int, error) {
 	// language = SQL
 	SQL := `INSERT INTO roles(role)
-            VALUES ($1)`
-	_, err := database.GramPanchayatDB.Exec(SQL, roleDetails.Role)
+            VALUES ($1)
+            RETURNING id`
+	var roleID int
+	err := tx.Get(&roleID, SQL, roleDetails.Role)
 	if err != nil {
 		logrus.Printf("AddRole:cannot add role:%v", err)
-		return err
+		return roleID, err
 	}
-	return nil
+	return roleID, nil
 }
-func BulkAddRole(roleDetails []models.RoleDetails) ([]int, error) {
+func BulkAddRole(roleDetails []models.RoleDetails, tx *sqlx.Tx) ([]int, error) {
 	// language = SQL
 	insertQuery := `INSERT INTO roles(role) VALUES($1)`
 	for i := range roleDetails {
@@ -149,7 +313,7 @@ func BulkAddRole(roleDetails []models.RoleDetails) ([]int, error) {
 	for _, value := range roleDetails {
 		values = append(values, value.Role)
 	}
-	rows, err := database.GramPanchayatDB.Query(insertQuery, values...)
+	rows, err := tx.Query(insertQuery, values...)
 	if err != nil {
 		logrus.Printf("AddRole:cannot add role:%v", err)
 		return nil, err
@@ -436,7 +600,10 @@ func GetUserInfo(userID int) (models.UserInfo, error) {
 		info.PanchayatList = panchayatOutput
 	}
 	// district level person cannot add new deaths
-	info.RegisterDeathEnabled = info.Role == utilities.Sachiv || info.Role == utilities.Sahayak
+	info.RegisterDeathEnabled, err = HasPermission(info.Role, utilities.PermissionDeathRegister)
+	if err != nil {
+		return info, err
+	}
 	return info, nil
 }
 
@@ -658,7 +825,10 @@ func GetTasks(searchText string, limit, page int) (models.Tasks, error) {
 		SQL := `SELECT tt.name as task_name,
                        tt.id as task_id,
                        r.id as role_id,
-                      r.role as role_name
+                      r.role as role_name,
+                      tt.sla_days,
+                      tt.sla_working_days,
+                      tt.is_monetary
                 FROM task_role t
                  join roles r on t.role_id = r.id
                  join task_types tt on t.task_type_id = tt.id
@@ -703,24 +873,24 @@ func GetTasks(searchText string, limit, page int) (models.Tasks, error) {
 	return Tasks, nil
 }
 
-func GetDeathCount(month time.Month, year, week int) (models.TotalDeaths, error) {
+func GetDeathCount(month time.Month, year, week int, jurisdiction models.Jurisdiction) (models.TotalDeaths, error) {
 	//language=SQL
 	SQL := `
-			SELECT (SELECT count(*)
-        			FROM death_details
-        			WHERE EXTRACT(MONTH FROM date_of_death) = $1
+			SELECT count(*) filter ( where EXTRACT(MONTH FROM date_of_death) = $1
           				AND EXTRACT(YEAR FROM date_of_death) = $2) AS month,
-       				(SELECT count(*)
-        			FROM death_details
-        			WHERE EXTRACT(WEEK FROM date_of_death) = $3 
-        			  AND EXTRACT(YEAR FROM date_of_death) = $2)   AS week,
-       				(SELECT count(*)
-        			FROM death_details
-        			WHERE date_of_death >= now()::DATE)            AS day;
+       			   count(*) filter ( where EXTRACT(WEEK FROM date_of_death) = $3 
+        			    AND EXTRACT(YEAR FROM date_of_death) = $2)   AS week,
+       			   count(*) filter ( where date_of_death >= now()::DATE)            AS day
+			FROM death_details JOIN gram_panchayat gp on death_details.gram_panchayat_id = gp.id
+			WHERE death_details.archived_at IS NULL
 			`
 
+	values := []interface{}{month, year, week}
+	scopeStr, _, values := jurisdictionClause(jurisdiction, "gp.tehsil_id", "gp.block_id", len(values), values)
+	SQL += scopeStr
+
 	var DeathCount models.TotalDeaths
-	err := database.GramPanchayatDB.Get(&DeathCount, SQL, month, year, week)
+	err := database.GramPanchayatDB.Get(&DeathCount, SQL, values...)
 
 	return DeathCount, err
 }
@@ -744,13 +914,26 @@ func GetDistrictPost() ([]models.DistrictPost, error) {
 }
 
 func GetGraphTesting(filter models.DeathFilter) ([]models.GraphDeatils, error) {
-	SQL := `SELECT date, coalesce(registered,0) as registered,coalesce(completed,0) as completed
+	SQL := `SELECT date, coalesce(registered,0) as registered,coalesce(completed,0) as completed,
+                   coalesce(tasks_completed,0) as tasks_completed,
+                   coalesce(tasks_not_applicable,0) as tasks_not_applicable,
+                   coalesce(tasks_rejected,0) as tasks_rejected
 			FROM  (SELECT death_details.created_at::TIMESTAMP::DATE as created_at,
                   count(death_details.id) as registered,
-                  count(death_details.id) filter ( where status = 'completed' )as completed
+                  count(death_details.id) filter ( where status = 'completed' )as completed,
+                  sum(outcomes.completed) as tasks_completed,
+                  sum(outcomes.not_applicable) as tasks_not_applicable,
+                  sum(outcomes.rejected) as tasks_rejected
 
 			FROM death_details JOIN gram_panchayat gp on death_details.gram_panchayat_id = gp.id
+			     LEFT JOIN LATERAL (SELECT count(*) filter ( where t.outcome = 'completed' ) as completed,
+			                               count(*) filter ( where t.outcome = 'not_applicable' ) as not_applicable,
+			                               count(*) filter ( where t.outcome = 'rejected' ) as rejected
+			                        FROM task t
+			                        WHERE t.death_id = death_details.id
+			                        AND t.archived_at IS NULL) outcomes ON true
 			WHERE death_details.created_at BETWEEN (now() - '9 days'::interval) AND now()
+			AND death_details.archived_at IS NULL
 `
 	values := make([]interface{}, 0)
 	num := 0
@@ -769,7 +952,7 @@ func GetGraphTesting(filter models.DeathFilter) ([]models.GraphDeatils, error) {
 				now(),
 				INTERVAL '1 day'
 				) as date ) as timer on timer.date=counter.created_at
-			group by timer.date,registered,completed
+			group by timer.date,registered,completed,tasks_completed,tasks_not_applicable,tasks_rejected
 			order by date`
 	}
 	if len(filter.TehsilID) > 0 {
@@ -785,7 +968,7 @@ func GetGraphTesting(filter models.DeathFilter) ([]models.GraphDeatils, error) {
 				now(),
 				INTERVAL '1 day'
 				) as date ) as timer on timer.date=counter.created_at
-			group by timer.date,registered,completed
+			group by timer.date,registered,completed,tasks_completed,tasks_not_applicable,tasks_rejected
 			order by date`
 	}
 	if len(filter.BlockID) > 0 {
@@ -801,10 +984,16 @@ func GetGraphTesting(filter models.DeathFilter) ([]models.GraphDeatils, error) {
 				now(),
 				INTERVAL '1 day'
 				) as date ) as timer on timer.date=counter.created_at
-			group by timer.date,registered,completed
+			group by timer.date,registered,completed,tasks_completed,tasks_not_applicable,tasks_rejected
 			order by date`
 	}
 
+	codeStr, num, values := deathCodeClause(filter, "death_details", num, values)
+	SQL += codeStr
+
+	scopeStr, _, values := jurisdictionClause(filter.Jurisdiction, "gp.tehsil_id", "gp.block_id", num, values)
+	SQL += scopeStr
+
 	if len(filter.GramPanchayatID) == 0 && len(filter.TehsilID) == 0 && len(filter.BlockID) == 0 {
 		str = `GROUP BY death_details.created_at::TIMESTAMP::DATE) as counter
 					  right join
@@ -814,7 +1003,7 @@ func GetGraphTesting(filter models.DeathFilter) ([]models.GraphDeatils, error) {
 				now(),
 				INTERVAL '1 day'
 				) as date ) as timer on timer.date=counter.created_at
-			group by timer.date,registered,completed
+			group by timer.date,registered,completed,tasks_completed,tasks_not_applicable,tasks_rejected
 			order by date`
 	}
 
@@ -830,13 +1019,26 @@ func GetGraphTesting(filter models.DeathFilter) ([]models.GraphDeatils, error) {
 }
 
 func GetGraph() ([]models.GraphDeatils, error) {
-	SQL := `SELECT date, coalesce(registered,0) as registered,coalesce(completed,0) as completed
+	SQL := `SELECT date, coalesce(registered,0) as registered,coalesce(completed,0) as completed,
+                   coalesce(tasks_completed,0) as tasks_completed,
+                   coalesce(tasks_not_applicable,0) as tasks_not_applicable,
+                   coalesce(tasks_rejected,0) as tasks_rejected
 			FROM  (SELECT death_details.created_at::TIMESTAMP::DATE as created_at,
                   count(death_details.id) as registered,
-                  count(death_details.id) filter ( where status = 'completed' )as completed
+                  count(death_details.id) filter ( where status = 'completed' )as completed,
+                  sum(outcomes.completed) as tasks_completed,
+                  sum(outcomes.not_applicable) as tasks_not_applicable,
+                  sum(outcomes.rejected) as tasks_rejected
 
 			FROM death_details JOIN gram_panchayat gp on death_details.gram_panchayat_id = gp.id
+			     LEFT JOIN LATERAL (SELECT count(*) filter ( where t.outcome = 'completed' ) as completed,
+			                               count(*) filter ( where t.outcome = 'not_applicable' ) as not_applicable,
+			                               count(*) filter ( where t.outcome = 'rejected' ) as rejected
+			                        FROM task t
+			                        WHERE t.death_id = death_details.id
+			                        AND t.archived_at IS NULL) outcomes ON true
 			WHERE death_details.created_at BETWEEN (now() - '10 days'::interval) AND now()
+			AND death_details.archived_at IS NULL
 			GROUP BY death_details.created_at::TIMESTAMP::DATE) as counter
           right join
       		( select date from
@@ -845,7 +1047,7 @@ func GetGraph() ([]models.GraphDeatils, error) {
                       			now(),
                      			 INTERVAL '1 day'
             ) as date ) as timer on timer.date=counter.created_at
-	group by timer.date,registered,completed
+	group by timer.date,registered,completed,tasks_completed,tasks_not_applicable,tasks_rejected
 	order by date
 `
 	graphDetails := make([]models.GraphDeatils, 0)
@@ -877,6 +1079,9 @@ select id,
        block_name,
        gaon_id,
        gaon_name,
+       cause_of_death,
+       place_of_death,
+       circumstance,
        task_details
 from (SELECT death_details.id,
              death_details.name,
@@ -896,21 +1101,35 @@ from (SELECT death_details.id,
              b.id as block_id,
              g.id as gaon_id,
              g.name as gaon_name,
+             coalesce(death_details.cause_of_death, '') as cause_of_death,
+             coalesce(death_details.place_of_death, '') as place_of_death,
+             coalesce(death_details.circumstance, '') as circumstance,
              count(t.status) filter (where t.status = 'completed')  as completed_tasks,
              count(t.status) filter (where t.status = 'new')        as new_tasks,
              count(t.status) filter (where t.status = 'processing') as processing_tasks,
-             count(*)                                               as all_tasks,
-             json_agg(json_build_object('taskId',t.id::text,'status', t.status::text, 'name',
+             count(t.id)                                            as all_tasks,
+             coalesce(json_agg(json_build_object('taskId',t.id::text,'status', t.status::text, 'name',
                                         task_types.name, 'startDate',
                                         t.start_date::DATE, 'completeDate',
                                         t.completed_date::DATE,
                  						'isRejected',t.is_rejected,
-                 						'reason',t.reason))    as task_details
+                 						'outcome',t.outcome,
+                 						'reasonCode',t.reason_code,
+                 						'assignedTo',t.user_id,
+                 						'createdAt',t.created_at,
+                 						'slaStartedAt',sss.sla_started_at,
+                 						'slaDays',task_types.sla_days,
+                 						'slaWorkingDays',task_types.sla_working_days,
+                 						'escalationLevel',t.escalation_level,
+                 						'blockedBy',coalesce(tbb.blocked_by, '{}'),
+                 						'reason',t.reason)) filter (where t.id is not null), '[]')    as task_details
       FROM death_details
                JOIN death_details_address dda on death_details.id = dda.death_detail_id
                JOIN address a on a.id = dda.address_id
-               JOIN task t on death_details.id = t.death_id
-               JOIN task_types on task_types.id = t.task_type_id
+               LEFT JOIN task t on death_details.id = t.death_id
+               LEFT JOIN task_types on task_types.id = t.task_type_id
+               LEFT JOIN task_blocked_by tbb on tbb.task_id = t.id
+               LEFT JOIN task_sla_start sss on sss.task_id = t.id
       		   JOIN gram_panchayat gp on death_details.gram_panchayat_id = gp.id
                JOIN gaon g on death_details.gaon_id = g.id
       		   JOIN tehsil t2 on gp.tehsil_id = t2.id
@@ -928,6 +1147,13 @@ from (SELECT death_details.id,
 		values = append(values, pq.StringArray(filter.TaskName))
 	}
 
+	if len(filter.Outcome) > 0 {
+		outcomeStr := fmt.Sprintf("AND t.outcome::TEXT =ANY($%d) ", num+1)
+		SQL += outcomeStr
+		num++
+		values = append(values, pq.StringArray(filter.Outcome))
+	}
+
 	if len(filter.TaskID) > 0 {
 		taskStr := fmt.Sprintf("AND task_type_id =ANY($%d) AND completed_date IS NULL ", num+1)
 		SQL += taskStr
@@ -935,15 +1161,20 @@ from (SELECT death_details.id,
 		values = append(values, pq.Array(filter.TaskID))
 	}
 
+	codeStr, num, values := deathCodeClause(filter, "death_details", num, values)
+	SQL += codeStr
+
 	groupByClause := "group by (death_details.id, death_details.name, death_details.phone_no, age, gender, aadhar_number, created_by, address, death_details.created_at, death_details.date_of_death, gp.name, b.id, t2.id, g.id) ORDER BY death_details.name) as death_details WHERE "
 	SQL += groupByClause
 	statusWhereClause := ""
 	if filter.Status == "processing" {
 		statusWhereClause = "(completed_tasks != all_tasks AND new_tasks = 0) "
 	} else if filter.Status == "completed" {
-		statusWhereClause = "completed_tasks = all_tasks "
+		statusWhereClause = "completed_tasks = all_tasks AND all_tasks > 0 "
 	} else if filter.Status == "new" {
 		statusWhereClause = "new_tasks > 0 "
+	} else if filter.Status == utilities.DeathStatusNoTasks {
+		statusWhereClause = "all_tasks = 0 "
 	} else {
 		statusWhereClause = "true "
 	}
@@ -973,6 +1204,8 @@ from (SELECT death_details.id,
 		num++
 		values = append(values, pq.Array(filter.BlockID))
 	}
+	scopeStr, num, values := jurisdictionClause(filter.Jurisdiction, "tehsil_id", "block_id", num, values)
+	SQL += scopeStr
 	if filter.FromDate.Unix() > 0 {
 		nameStr := fmt.Sprintf("AND created_at::date >= $%d::date ", num+1)
 		SQL += nameStr
@@ -1073,7 +1306,7 @@ func EditUser(name, phoneNo string, userID int, tx *sqlx.Tx) error {
 	return nil
 }
 
-func AddBlock(block models.BlockDetails) (int, error) {
+func AddBlock(block models.BlockDetails, tx *sqlx.Tx) (int, error) {
 	// language=SQL
 	SQL := `
 			INSERT INTO block (name)
@@ -1081,11 +1314,11 @@ func AddBlock(block models.BlockDetails) (int, error) {
 			RETURNING id
 			`
 	var id int
-	err := database.GramPanchayatDB.Get(&id, SQL, block.BlockName)
+	err := tx.Get(&id, SQL, block.BlockName)
 
 	return id, err
 }
-func UpdateBlock(block models.BlockDetails) error {
+func UpdateBlock(block models.BlockDetails, tx *sqlx.Tx) error {
 	// language=SQL
 	SQL := `
 			UPDATE block 
@@ -1093,7 +1326,7 @@ func UpdateBlock(block models.BlockDetails) error {
 			WHERE id=$2
 			AND archived_at IS NULL 
 			`
-	_, err := database.GramPanchayatDB.Exec(SQL, block.BlockName, block.BlockID)
+	_, err := tx.Exec(SQL, block.BlockName, block.BlockID)
 
 	return err
 }
@@ -1135,6 +1368,9 @@ select id,
        block_name,
        gaon_id,
        gaon_name, 
+       cause_of_death,
+       place_of_death,
+       circumstance,
        is_reviewed,
        comment,
        reviewed_by,
@@ -1158,12 +1394,24 @@ from (SELECT death_review.id,
              b.id as block_id,
              g.id gaon_id,
              g.name as gaon_name, 
-             json_agg(json_build_object('taskId',t.id::text,'status', t.status::text, 'name',
+             coalesce(death_details.cause_of_death, '') as cause_of_death,
+             coalesce(death_details.place_of_death, '') as place_of_death,
+             coalesce(death_details.circumstance, '') as circumstance,
+             coalesce(json_agg(json_build_object('taskId',t.id::text,'status', t.status::text, 'name',
                                         task_types.name, 'startDate',
                                         t.start_date::DATE, 'completeDate',
                                         t.completed_date::DATE,
                  						'isRejected',t.is_rejected,
-                 						'reason',t.reason))    as task_details,
+                 						'outcome',t.outcome,
+                 						'reasonCode',t.reason_code,
+                 						'assignedTo',t.user_id,
+                 						'createdAt',t.created_at,
+                 						'slaStartedAt',sss.sla_started_at,
+                 						'slaDays',task_types.sla_days,
+                 						'slaWorkingDays',task_types.sla_working_days,
+                 						'escalationLevel',t.escalation_level,
+                 						'blockedBy',coalesce(tbb.blocked_by, '{}'),
+                 						'reason',t.reason)) filter (where t.id is not null), '[]')    as task_details,
           death_review.is_reviewed,
           case when is_reviewed = 'true' then (death_review.comment) end as comment,
           case when is_reviewed = 'true' then (death_review.review_by) end as reviewed_by,
@@ -1172,8 +1420,10 @@ from (SELECT death_review.id,
           	   JOIN death_review on death_details.id = death_review.death_detail_id
                JOIN death_details_address dda on death_details.id = dda.death_detail_id
                JOIN address a on a.id = dda.address_id
-               JOIN task t on death_details.id = t.death_id
-               JOIN task_types on task_types.id = t.task_type_id
+               LEFT JOIN task t on death_details.id = t.death_id
+               LEFT JOIN task_types on task_types.id = t.task_type_id
+               LEFT JOIN task_blocked_by tbb on tbb.task_id = t.id
+               LEFT JOIN task_sla_start sss on sss.task_id = t.id
       		   JOIN gram_panchayat gp on death_details.gram_panchayat_id = gp.id
                JOIN gaon g on g.id = death_details.gaon_id
       		   JOIN tehsil t2 on gp.tehsil_id = t2.id
@@ -1203,6 +1453,8 @@ from (SELECT death_review.id,
 		num++
 		values = append(values, pq.Array(filter.BlockID))
 	}
+	scopeStr, _, values := jurisdictionClause(filter.Jurisdiction, "gp.tehsil_id", "gp.block_id", num, values)
+	SQL += scopeStr
 	SQL += `GROUP BY death_details.id, death_details.name, death_details.phone_no, age, gender, aadhar_number, created_by, address, death_details.created_at, death_details.date_of_death, death_details.gram_panchayat_id, gp.name, death_review.created_at, death_review.is_reviewed, death_review.comment, death_review.review_by, death_review.reviewed_at, death_review.id, t2.id, b.id, g.id
 			ORDER BY death_review.created_at DESC
 			  ) as detail
@@ -1213,19 +1465,23 @@ from (SELECT death_review.id,
 	return death, err
 }
 
+// GetCompletedDeaths returns the deaths whose last task was completed on the date, a death no task was created for
+// is done on the day it was registered
 func GetCompletedDeaths(date time.Time) ([]int, error) {
 	// language=SQL
 	SQL := `
 			SELECT id
 			FROM (SELECT death_details.id,
+			             death_details.created_at,
              			 count(t.status) filter (where t.status = 'completed')           as completed_tasks,
-             			 count(*)                                                        as all_task,
-             			 count(*) filter ( where t.completed_date::DATE = $1 ) as completed_yesterday
+             			 count(t.id)                                                     as all_task,
+             			 count(t.id) filter ( where t.completed_date::DATE = $1 ) as completed_yesterday
       			  from death_details
-               			   join task t on death_details.id = t.death_id
+               			   left join task t on death_details.id = t.death_id
+      			  where death_details.archived_at IS NULL
       			  group by death_details.id) as death_re
 			where completed_tasks = all_task
-  			  and completed_yesterday > 0;
+  			  and (completed_yesterday > 0 or (all_task = 0 and created_at::DATE = $1::DATE));
 `
 	deathID := make([]int, 0)
 	err := database.GramPanchayatDB.Select(&deathID, SQL, date)
@@ -1254,7 +1510,7 @@ func BulkInsertDeathReviewDetails(deathIDs []int) error {
 	return nil
 }
 
-func ReviewDeathDetails(deathDetailsReview models.RandomDeath, userID int) error {
+func ReviewDeathDetails(deathDetailsReview models.RandomDeath, userID int, tx *sqlx.Tx) error {
 	SQL := `UPDATE death_review
             SET    is_reviewed = true,
                    comment = $1,
@@ -1263,7 +1519,7 @@ func ReviewDeathDetails(deathDetailsReview models.RandomDeath, userID int) error
             WHERE  death_detail_id = $3
             AND    id = $4
             `
-	_, err := database.GramPanchayatDB.Exec(SQL, deathDetailsReview.ReviewComment, userID, deathDetailsReview.DeathID, deathDetailsReview.ID)
+	_, err := tx.Exec(SQL, deathDetailsReview.ReviewComment, userID, deathDetailsReview.DeathID, deathDetailsReview.ID)
 	if err != nil {
 		logrus.Printf("ReviewDeathDetails:  cannot rview death:%v", err)
 		return err
@@ -1322,7 +1578,7 @@ func GetGaon() ([]models.GaonDetails, error) {
 	return gaonDetails, nil
 }
 
-func UpdateGaon(gaon models.GaonDetails) error {
+func UpdateGaon(gaon models.GaonDetails, tx *sqlx.Tx) error {
 	// language=SQL
 	SQL := `
 			UPDATE gaon 
@@ -1331,7 +1587,7 @@ func UpdateGaon(gaon models.GaonDetails) error {
 			WHERE id=$3
 			AND archived_at IS NULL 
 			`
-	_, err := database.GramPanchayatDB.Exec(SQL, gaon.GaonName, gaon.GramPanchayatID, gaon.ID)
+	_, err := tx.Exec(SQL, gaon.GaonName, gaon.GramPanchayatID, gaon.ID)
 
 	return err
 }
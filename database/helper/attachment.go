@@ -0,0 +1,91 @@
+package helper
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+)
+
+func AddAttachment(attachment models.Attachment, tx *sqlx.Tx) (int, error) {
+	// language=SQL
+	SQL := `INSERT INTO attachment(death_id, task_id, document_type, file_name, content_type, size_bytes, storage_key, uploaded_by)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING id`
+
+	var attachmentID int
+
+	err := tx.Get(&attachmentID, SQL, attachment.DeathID, attachment.TaskID, attachment.DocumentType, attachment.FileName,
+		attachment.ContentType, attachment.SizeBytes, attachment.StorageKey, attachment.UploadedBy)
+	if err != nil {
+		logrus.Printf("AddAttachment: cannot add attachment:%v", err)
+		return attachmentID, err
+	}
+	return attachmentID, nil
+}
+
+// GetAttachments lists the attachments of the death including those of its tasks, or only those of the task when taskID is set
+func GetAttachments(deathID, taskID int) ([]models.Attachment, error) {
+	// language=SQL
+	SQL := `SELECT id,
+                   death_id,
+                   task_id,
+                   document_type,
+                   file_name,
+                   content_type,
+                   size_bytes,
+                   storage_key,
+                   uploaded_by,
+                   created_at
+            FROM   attachment
+            WHERE  death_id = $1
+            AND    ($2 = 0 OR task_id = $2)
+            AND    archived_at IS NULL
+            ORDER BY created_at`
+
+	attachments := make([]models.Attachment, 0)
+
+	err := database.GramPanchayatDB.Select(&attachments, SQL, deathID, taskID)
+	if err != nil {
+		logrus.Printf("GetAttachments: cannot get attachments:%v", err)
+		return attachments, err
+	}
+	return attachments, nil
+}
+
+func GetAttachment(attachmentID int) (models.Attachment, error) {
+	// language=SQL
+	SQL := `SELECT id,
+                   death_id,
+                   task_id,
+                   document_type,
+                   file_name,
+                   content_type,
+                   size_bytes,
+                   storage_key,
+                   uploaded_by,
+                   created_at
+            FROM   attachment
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	var attachment models.Attachment
+
+	err := database.GramPanchayatDB.Get(&attachment, SQL, attachmentID)
+	return attachment, err
+}
+
+func ArchiveAttachment(attachmentID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE attachment
+            SET    archived_at = now()
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, attachmentID)
+	if err != nil {
+		logrus.Printf("ArchiveAttachment: cannot archive attachment:%v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package helper
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"time"
+)
+
+// auditChainLock is the advisory lock key that keeps appends to the hash chain in order
+const auditChainLock = 7_362_001
+
+// auditSnapshots are the queries returning an entity as json, secrets of users never end up in the log
+var auditSnapshots = map[string]string{
+	utilities.EntityDeath:           `SELECT to_jsonb(t)::text FROM death_details t WHERE t.id = $1`,
+	utilities.EntityDeathReview:     `SELECT to_jsonb(t)::text FROM death_review t WHERE t.id = $1`,
+	utilities.EntityTask:            `SELECT to_jsonb(t)::text FROM task t WHERE t.id = $1`,
+	utilities.EntityTaskType:        `SELECT to_jsonb(t)::text FROM task_types t WHERE t.id = $1`,
+	utilities.EntityTehsil:          `SELECT to_jsonb(t)::text FROM tehsil t WHERE t.id = $1`,
+	utilities.EntityGramPanchayat:   `SELECT to_jsonb(t)::text FROM gram_panchayat t WHERE t.id = $1`,
+	utilities.EntityGaon:            `SELECT to_jsonb(t)::text FROM gaon t WHERE t.id = $1`,
+	utilities.EntityBlock:           `SELECT to_jsonb(t)::text FROM block t WHERE t.id = $1`,
+	utilities.EntityUser:            `SELECT (to_jsonb(t) - 'password_hash' - 'totp_secret' - 'totp_pending_secret')::text FROM users t WHERE t.id = $1`,
+	utilities.EntityRole:            `SELECT to_jsonb(t)::text FROM roles t WHERE t.id = $1`,
+	utilities.EntityAttachment:      `SELECT (to_jsonb(t) - 'storage_key')::text FROM attachment t WHERE t.id = $1`,
+	utilities.EntityComment:         `SELECT to_jsonb(t)::text FROM comment t WHERE t.id = $1`,
+	utilities.EntityBeneficiary:     `SELECT to_jsonb(t)::text FROM beneficiary t WHERE t.id = $1`,
+	utilities.EntitySanction:        `SELECT to_jsonb(t)::text FROM task_sanction t WHERE t.id = $1`,
+	utilities.EntityPayout:          `SELECT to_jsonb(t)::text FROM payout t WHERE t.id = $1`,
+	utilities.EntityDeathCode:       `SELECT to_jsonb(t)::text FROM death_code t WHERE t.id = $1`,
+	utilities.EntityTaskTypePrereqs: `SELECT coalesce(jsonb_agg(ttp.prerequisite_task_type_id ORDER BY ttp.prerequisite_task_type_id), '[]')::text FROM task_type_prerequisite ttp WHERE ttp.task_type_id = $1 AND ttp.archived_at IS NULL`,
+	utilities.EntityTaskTypeRules:   `SELECT coalesce(jsonb_agg(jsonb_build_object('attribute', r.attribute, 'operator', r.operator, 'values', r.rule_values, 'description', r.description) ORDER BY r.id), '[]')::text FROM task_type_eligibility_rule r WHERE r.task_type_id = $1 AND r.archived_at IS NULL`,
+	utilities.EntityHoliday:         `SELECT to_jsonb(t)::text FROM holiday t WHERE t.id = $1`,
+	utilities.EntityRolePermissions: `SELECT coalesce(jsonb_agg(p.name ORDER BY p.name), '[]')::text FROM role_permissions rp JOIN permissions p on p.id = rp.permission_id WHERE rp.role_id = $1 AND rp.archived_at IS NULL`,
+}
+
+// auditHashInput is what every hash of the chain is computed over
+type auditHashInput struct {
+	PrevHash  string          `json:"prevHash"`
+	ActorID   int             `json:"actorId"`
+	ActorRole string          `json:"actorRole"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  int             `json:"entityId"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt string          `json:"createdAt"`
+}
+
+func auditHash(entry models.AuditLog) (string, error) {
+	input, err := json.Marshal(auditHashInput{
+		PrevHash:  entry.PrevHash,
+		ActorID:   entry.ActorID,
+		ActorRole: entry.ActorRole,
+		Action:    entry.Action,
+		Entity:    entry.Entity,
+		EntityID:  entry.EntityID,
+		Before:    entry.Before,
+		After:     entry.After,
+		CreatedAt: entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(input)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// GetAuditSnapshot returns the current row of the entity as json, or json null when it does not exist
+func GetAuditSnapshot(entity string, entityID int, tx *sqlx.Tx) (json.RawMessage, error) {
+	SQL, ok := auditSnapshots[entity]
+	if !ok {
+		return nil, fmt.Errorf("GetAuditSnapshot: unknown entity %q", entity)
+	}
+
+	var snapshot string
+
+	err := tx.Get(&snapshot, SQL, entityID)
+	if err == sql.ErrNoRows {
+		return json.RawMessage("null"), nil
+	}
+	if err != nil {
+		logrus.Printf("GetAuditSnapshot: cannot get snapshot of %s:%v", entity, err)
+		return nil, err
+	}
+	return json.RawMessage(snapshot), nil
+}
+
+// AddAuditLog appends an entry to the hash chain, it must run in the transaction of the change it records
+func AddAuditLog(actor models.ContextValues, action, entity string, entityID int, before, after json.RawMessage, tx *sqlx.Tx) error {
+	if before == nil {
+		before = json.RawMessage("null")
+	}
+	if after == nil {
+		after = json.RawMessage("null")
+	}
+
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLock)
+	if err != nil {
+		logrus.Printf("AddAuditLog: cannot lock audit chain:%v", err)
+		return err
+	}
+
+	entry := models.AuditLog{
+		ActorID:   actor.ID,
+		ActorRole: actor.Role,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		Before:    before,
+		After:     after,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	// language=SQL
+	SQL := `SELECT hash
+            FROM   audit_log
+            ORDER BY id DESC
+            LIMIT 1`
+
+	err = tx.Get(&entry.PrevHash, SQL)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Printf("AddAuditLog: cannot get last hash:%v", err)
+		return err
+	}
+
+	entry.Hash, err = auditHash(entry)
+	if err != nil {
+		logrus.Printf("AddAuditLog: cannot hash entry:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO audit_log(actor_id, actor_role, action, entity, entity_id, before, after, created_at, prev_hash, hash)
+           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err = tx.Exec(SQL, entry.ActorID, entry.ActorRole, entry.Action, entry.Entity, entry.EntityID,
+		string(entry.Before), string(entry.After), entry.CreatedAt, entry.PrevHash, entry.Hash)
+	if err != nil {
+		logrus.Printf("AddAuditLog: cannot add audit log:%v", err)
+		return err
+	}
+	return nil
+}
+
+// AuditChange records the entity as it was before and after change
+func AuditChange(actor models.ContextValues, action, entity string, entityID int, tx *sqlx.Tx, change func() error) error {
+	before, err := GetAuditSnapshot(entity, entityID, tx)
+	if err != nil {
+		return err
+	}
+
+	err = change()
+	if err != nil {
+		return err
+	}
+
+	after, err := GetAuditSnapshot(entity, entityID, tx)
+	if err != nil {
+		return err
+	}
+	return AddAuditLog(actor, action, entity, entityID, before, after, tx)
+}
+
+// AuditCreate records an entity that was just added
+func AuditCreate(actor models.ContextValues, action, entity string, entityID int, tx *sqlx.Tx) error {
+	after, err := GetAuditSnapshot(entity, entityID, tx)
+	if err != nil {
+		return err
+	}
+	return AddAuditLog(actor, action, entity, entityID, nil, after, tx)
+}
+
+func GetAuditLogs(filter models.AuditLogFilter) ([]models.AuditLog, error) {
+	// language=SQL
+	SQL := `SELECT id,
+                   actor_id,
+                   actor_role,
+                   action,
+                   entity,
+                   entity_id,
+                   before,
+                   after,
+                   created_at,
+                   prev_hash,
+                   hash
+            FROM   audit_log
+            WHERE  true `
+
+	values := make([]interface{}, 0)
+	num := 0
+
+	if filter.ActorID != 0 {
+		SQL += fmt.Sprintf("AND actor_id = $%d ", num+1)
+		num++
+		values = append(values, filter.ActorID)
+	}
+
+	if filter.Action != "" {
+		SQL += fmt.Sprintf("AND action = $%d ", num+1)
+		num++
+		values = append(values, filter.Action)
+	}
+
+	if filter.Entity != "" {
+		SQL += fmt.Sprintf("AND entity = $%d ", num+1)
+		num++
+		values = append(values, filter.Entity)
+	}
+
+	if filter.EntityID != 0 {
+		SQL += fmt.Sprintf("AND entity_id = $%d ", num+1)
+		num++
+		values = append(values, filter.EntityID)
+	}
+
+	if !filter.FromDate.IsZero() {
+		SQL += fmt.Sprintf("AND created_at::DATE >= $%d ", num+1)
+		num++
+		values = append(values, filter.FromDate)
+	}
+
+	if !filter.ToDate.IsZero() {
+		SQL += fmt.Sprintf("AND created_at::DATE <= $%d ", num+1)
+		num++
+		values = append(values, filter.ToDate)
+	}
+
+	SQL += fmt.Sprintf("ORDER BY id DESC LIMIT $%d OFFSET $%d", num+1, num+2)
+	values = append(values, filter.Limit, filter.Limit*filter.Page)
+
+	auditLogs := make([]models.AuditLog, 0)
+
+	err := database.GramPanchayatDB.Select(&auditLogs, SQL, values...)
+	if err != nil {
+		logrus.Printf("GetAuditLogs: cannot get audit logs:%v", err)
+		return auditLogs, err
+	}
+	return auditLogs, nil
+}
+
+// isAuditEntryLinked checks the entry points at the hash before it and still hashes to what was stored
+func isAuditEntryLinked(prevHash string, entry models.AuditLog) (bool, error) {
+	hash, err := auditHash(entry)
+	if err != nil {
+		return false, err
+	}
+	return entry.PrevHash == prevHash && entry.Hash == hash, nil
+}
+
+// VerifyAuditChain walks the whole log and recomputes every hash,
+// an edited entry changes its hash and a removed one breaks the link of the next entry
+func VerifyAuditChain() (models.AuditChainStatus, error) {
+	status := models.AuditChainStatus{IsValid: true}
+
+	// language=SQL
+	SQL := `SELECT id,
+                   actor_id,
+                   actor_role,
+                   action,
+                   entity,
+                   entity_id,
+                   before,
+                   after,
+                   created_at,
+                   prev_hash,
+                   hash
+            FROM   audit_log
+            ORDER BY id`
+
+	rows, err := database.GramPanchayatDB.Queryx(SQL)
+	if err != nil {
+		logrus.Printf("VerifyAuditChain: cannot get audit logs:%v", err)
+		return status, err
+	}
+	defer rows.Close()
+
+	prevHash := ""
+	for rows.Next() {
+		var entry models.AuditLog
+		err = rows.StructScan(&entry)
+		if err != nil {
+			logrus.Printf("VerifyAuditChain: cannot scan audit log:%v", err)
+			return status, err
+		}
+		status.Checked++
+
+		isLinked, err := isAuditEntryLinked(prevHash, entry)
+		if err != nil {
+			return status, err
+		}
+		if !isLinked {
+			status.IsValid = false
+			status.BrokenAtID = entry.ID
+			return status, nil
+		}
+		prevHash = entry.Hash
+	}
+	return status, rows.Err()
+}
@@ -0,0 +1,104 @@
+package helper
+
+import (
+	"encoding/json"
+	"grampanchayat/models"
+	"testing"
+	"time"
+)
+
+// auditChain builds a chain of entries the way AddAuditLog appends them
+func auditChain(t *testing.T, n int) []models.AuditLog {
+	t.Helper()
+	chain := make([]models.AuditLog, 0, n)
+	prevHash := ""
+	for i := 1; i <= n; i++ {
+		entry := models.AuditLog{
+			ID:        i,
+			ActorID:   7,
+			ActorRole: "Admin",
+			Action:    "death.edit",
+			Entity:    "death_details",
+			EntityID:  42,
+			Before:    json.RawMessage(`{"name": "Ram Lal"}`),
+			After:     json.RawMessage(`{"name": "Ram Lal Verma"}`),
+			CreatedAt: time.Date(2026, 10, 12, 10, 30, i, 0, time.UTC),
+			PrevHash:  prevHash,
+		}
+		hash, err := auditHash(entry)
+		if err != nil {
+			t.Fatalf("auditHash() error = %v", err)
+		}
+		entry.Hash = hash
+		chain = append(chain, entry)
+		prevHash = hash
+	}
+	return chain
+}
+
+// brokenAt walks the chain like VerifyAuditChain, 0 when every entry is linked
+func brokenAt(t *testing.T, chain []models.AuditLog) int {
+	t.Helper()
+	prevHash := ""
+	for _, entry := range chain {
+		isLinked, err := isAuditEntryLinked(prevHash, entry)
+		if err != nil {
+			t.Fatalf("isAuditEntryLinked() error = %v", err)
+		}
+		if !isLinked {
+			return entry.ID
+		}
+		prevHash = entry.Hash
+	}
+	return 0
+}
+
+func TestAuditChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(chain []models.AuditLog) []models.AuditLog
+		want   int
+	}{
+		{"untouched", func(chain []models.AuditLog) []models.AuditLog { return chain }, 0},
+		{"other time zone", func(chain []models.AuditLog) []models.AuditLog {
+			chain[1].CreatedAt = chain[1].CreatedAt.In(time.FixedZone("IST", 5*60*60+30*60))
+			return chain
+		}, 0},
+		{"edited after", func(chain []models.AuditLog) []models.AuditLog {
+			chain[1].After = json.RawMessage(`{"name": "Shyam Lal"}`)
+			return chain
+		}, 2},
+		{"edited actor", func(chain []models.AuditLog) []models.AuditLog {
+			chain[0].ActorID = 8
+			return chain
+		}, 1},
+		{"edited time", func(chain []models.AuditLog) []models.AuditLog {
+			chain[2].CreatedAt = chain[2].CreatedAt.Add(time.Hour)
+			return chain
+		}, 3},
+		{"edited and rehashed", func(chain []models.AuditLog) []models.AuditLog {
+			chain[1].Action = "death.archive"
+			chain[1].Hash, _ = auditHash(chain[1])
+			return chain
+		}, 3},
+		{"removed entry", func(chain []models.AuditLog) []models.AuditLog {
+			return append(chain[:1], chain[2:]...)
+		}, 3},
+		{"removed first entry", func(chain []models.AuditLog) []models.AuditLog {
+			return chain[1:]
+		}, 2},
+		{"swapped entries", func(chain []models.AuditLog) []models.AuditLog {
+			chain[1], chain[2] = chain[2], chain[1]
+			return chain
+		}, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chain := test.tamper(auditChain(t, 3))
+			if got := brokenAt(t, chain); got != test.want {
+				t.Errorf("chain broken at %d, want %d", got, test.want)
+			}
+		})
+	}
+}
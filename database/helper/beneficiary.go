@@ -0,0 +1,133 @@
+package helper
+
+import (
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+)
+
+var ErrDuplicateBeneficiary = errors.New("beneficiary with this aadhar number is already nominated for the death")
+
+func AddBeneficiary(beneficiary models.Beneficiary, createdBy int, tx *sqlx.Tx) (int, error) {
+	// language=SQL
+	SQL := `INSERT INTO beneficiary(death_id, name, relation, phone_no, aadhar_number, bank_account_number, ifsc_code, created_by)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+            RETURNING id`
+
+	var beneficiaryID int
+
+	err := tx.Get(&beneficiaryID, SQL, beneficiary.DeathID, beneficiary.Name, beneficiary.Relation, beneficiary.PhoneNo,
+		beneficiary.AadharNumber, beneficiary.BankAccountNumber, beneficiary.IFSCCode, createdBy)
+	if isUniqueViolation(err) {
+		return beneficiaryID, ErrDuplicateBeneficiary
+	}
+	if err != nil {
+		logrus.Printf("AddBeneficiary: cannot add beneficiary:%v", err)
+		return beneficiaryID, err
+	}
+	return beneficiaryID, nil
+}
+
+func UpdateBeneficiary(beneficiary models.Beneficiary, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE beneficiary
+            SET    name = $1,
+                   relation = $2,
+                   phone_no = $3,
+                   aadhar_number = $4,
+                   bank_account_number = $5,
+                   ifsc_code = $6,
+                   updated_at = now()
+            WHERE  id = $7
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, beneficiary.Name, beneficiary.Relation, beneficiary.PhoneNo, beneficiary.AadharNumber,
+		beneficiary.BankAccountNumber, beneficiary.IFSCCode, beneficiary.ID)
+	if isUniqueViolation(err) {
+		return ErrDuplicateBeneficiary
+	}
+	if err != nil {
+		logrus.Printf("UpdateBeneficiary: cannot update beneficiary:%v", err)
+		return err
+	}
+	return nil
+}
+
+func ArchiveBeneficiary(beneficiaryID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE beneficiary
+            SET    archived_at = now()
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, beneficiaryID)
+	if err != nil {
+		logrus.Printf("ArchiveBeneficiary: cannot archive beneficiary:%v", err)
+		return err
+	}
+	return nil
+}
+
+func GetBeneficiaries(deathID int) ([]models.Beneficiary, error) {
+	// language=SQL
+	SQL := `SELECT id,
+                   death_id,
+                   name,
+                   relation,
+                   phone_no,
+                   aadhar_number,
+                   bank_account_number,
+                   ifsc_code,
+                   created_by,
+                   created_at
+            FROM   beneficiary
+            WHERE  death_id = $1
+            AND    archived_at IS NULL
+            ORDER BY id`
+
+	beneficiaries := make([]models.Beneficiary, 0)
+
+	err := database.GramPanchayatDB.Select(&beneficiaries, SQL, deathID)
+	if err != nil {
+		logrus.Printf("GetBeneficiaries: cannot get beneficiaries:%v", err)
+		return beneficiaries, err
+	}
+	return beneficiaries, nil
+}
+
+// GetBeneficiaryDeathID returns sql.ErrNoRows when the beneficiary does not exist or was removed
+func GetBeneficiaryDeathID(beneficiaryID int) (int, error) {
+	// language=SQL
+	SQL := `SELECT death_id
+            FROM   beneficiary
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	var deathID int
+
+	err := database.GramPanchayatDB.Get(&deathID, SQL, beneficiaryID)
+	return deathID, err
+}
+
+// HasTaskOnDeath tells whether the death has an open or closed task of one of the task types
+func HasTaskOnDeath(deathID int, taskTypes []string) (bool, error) {
+	// language=SQL
+	SQL := `SELECT EXISTS(SELECT 1
+                          FROM   task t
+                                 JOIN task_types tt on tt.id = t.task_type_id
+                          WHERE  t.death_id = $1
+                          AND    tt.name = ANY($2)
+                          AND    t.archived_at IS NULL)`
+
+	var hasTask bool
+
+	err := database.GramPanchayatDB.Get(&hasTask, SQL, deathID, pq.StringArray(taskTypes))
+	if err != nil {
+		logrus.Printf("HasTaskOnDeath: cannot check tasks:%v", err)
+		return false, err
+	}
+	return hasTask, nil
+}
@@ -0,0 +1,92 @@
+package helper
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+)
+
+func AddComment(comment models.Comment, tx *sqlx.Tx) (int, error) {
+	// language=SQL
+	SQL := `INSERT INTO comment(death_id, task_id, author_id, author_role, body)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING id`
+
+	var commentID int
+
+	err := tx.Get(&commentID, SQL, comment.DeathID, comment.TaskID, comment.AuthorID, comment.AuthorRole, comment.Body)
+	if err != nil {
+		logrus.Printf("AddComment: cannot add comment:%v", err)
+		return commentID, err
+	}
+	return commentID, nil
+}
+
+// GetComments returns the thread of the death including the comments on its tasks, or only the task's when taskID is set
+func GetComments(deathID, taskID int) ([]models.Comment, error) {
+	// language=SQL
+	SQL := `SELECT c.id,
+                   c.death_id,
+                   c.task_id,
+                   c.author_id,
+                   u.name as author_name,
+                   c.author_role,
+                   c.body,
+                   c.created_at
+            FROM   comment c
+                   JOIN users u on u.id = c.author_id
+            WHERE  c.death_id = $1
+            AND    ($2 = 0 OR c.task_id = $2)
+            AND    c.archived_at IS NULL
+            ORDER BY c.created_at`
+
+	comments := make([]models.Comment, 0)
+
+	err := database.GramPanchayatDB.Select(&comments, SQL, deathID, taskID)
+	if err != nil {
+		logrus.Printf("GetComments: cannot get comments:%v", err)
+		return comments, err
+	}
+	return comments, nil
+}
+
+// GetDeathsComments returns the threads of many deaths at once, every death gets an entry even without comments
+func GetDeathsComments(deathIDs []int) (map[int][]models.Comment, error) {
+	threads := make(map[int][]models.Comment, len(deathIDs))
+	for _, deathID := range deathIDs {
+		threads[deathID] = make([]models.Comment, 0)
+	}
+	if len(deathIDs) == 0 {
+		return threads, nil
+	}
+
+	// language=SQL
+	SQL := `SELECT c.id,
+                   c.death_id,
+                   c.task_id,
+                   c.author_id,
+                   u.name as author_name,
+                   c.author_role,
+                   c.body,
+                   c.created_at
+            FROM   comment c
+                   JOIN users u on u.id = c.author_id
+            WHERE  c.death_id = ANY($1)
+            AND    c.archived_at IS NULL
+            ORDER BY c.created_at`
+
+	comments := make([]models.Comment, 0)
+
+	err := database.GramPanchayatDB.Select(&comments, SQL, pq.Array(deathIDs))
+	if err != nil {
+		logrus.Printf("GetDeathsComments: cannot get comments:%v", err)
+		return threads, err
+	}
+
+	for _, comment := range comments {
+		threads[comment.DeathID] = append(threads[comment.DeathID], comment)
+	}
+	return threads, nil
+}
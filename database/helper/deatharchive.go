@@ -0,0 +1,197 @@
+package helper
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+)
+
+var (
+	ErrDeathArchived    = errors.New("death is already archived")
+	ErrDeathNotArchived = errors.New("death is not archived")
+)
+
+// lockDeathArchive returns when the death was archived, sql.ErrNoRows when it does not exist
+func lockDeathArchive(deathID int, tx *sqlx.Tx) (sql.NullTime, error) {
+	// language=SQL
+	SQL := `SELECT archived_at
+            FROM   death_details
+            WHERE  id = $1
+            FOR UPDATE`
+
+	var archivedAt sql.NullTime
+
+	err := tx.Get(&archivedAt, SQL, deathID)
+	return archivedAt, err
+}
+
+// ArchiveDeath takes the death with its tasks and reviews out of every listing and count.
+// All of them get the same archived_at, now() being the time of the transaction, so RestoreDeath brings back only these.
+func ArchiveDeath(deathID, archivedBy int, reason string, tx *sqlx.Tx) error {
+	archivedAt, err := lockDeathArchive(deathID, tx)
+	if err != nil {
+		logrus.Printf("ArchiveDeath: cannot get death:%v", err)
+		return err
+	}
+	if archivedAt.Valid {
+		return ErrDeathArchived
+	}
+
+	// language=SQL
+	SQL := `UPDATE death_details
+            SET    archived_at = now(),
+                   archived_by = $2,
+                   archive_reason = $3
+            WHERE  id = $1`
+
+	_, err = tx.Exec(SQL, deathID, archivedBy, reason)
+	if err != nil {
+		logrus.Printf("ArchiveDeath: cannot archive death:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `UPDATE task
+           SET    archived_at = now()
+           WHERE  death_id = $1
+           AND    archived_at IS NULL`
+
+	_, err = tx.Exec(SQL, deathID)
+	if err != nil {
+		logrus.Printf("ArchiveDeath: cannot archive tasks:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `UPDATE death_review
+           SET    archived_at = now()
+           WHERE  death_detail_id = $1
+           AND    archived_at IS NULL`
+
+	_, err = tx.Exec(SQL, deathID)
+	if err != nil {
+		logrus.Printf("ArchiveDeath: cannot archive reviews:%v", err)
+		return err
+	}
+	return nil
+}
+
+// RestoreDeath brings back the death with the tasks and reviews archived along with it
+func RestoreDeath(deathID int, tx *sqlx.Tx) error {
+	archivedAt, err := lockDeathArchive(deathID, tx)
+	if err != nil {
+		logrus.Printf("RestoreDeath: cannot get death:%v", err)
+		return err
+	}
+	if !archivedAt.Valid {
+		return ErrDeathNotArchived
+	}
+
+	// language=SQL
+	SQL := `UPDATE task
+            SET    archived_at = NULL
+            WHERE  death_id = $1
+            AND    archived_at = $2`
+
+	_, err = tx.Exec(SQL, deathID, archivedAt.Time)
+	if err != nil {
+		logrus.Printf("RestoreDeath: cannot restore tasks:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `UPDATE death_review
+           SET    archived_at = NULL
+           WHERE  death_detail_id = $1
+           AND    archived_at = $2`
+
+	_, err = tx.Exec(SQL, deathID, archivedAt.Time)
+	if err != nil {
+		logrus.Printf("RestoreDeath: cannot restore reviews:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `UPDATE death_details
+           SET    archived_at = NULL,
+                  archived_by = NULL,
+                  archive_reason = NULL
+           WHERE  id = $1`
+
+	_, err = tx.Exec(SQL, deathID)
+	if err != nil {
+		logrus.Printf("RestoreDeath: cannot restore death:%v", err)
+		return err
+	}
+	return nil
+}
+
+// GetArchivedDeaths lists the archived deaths of the jurisdiction, the latest archived first
+func GetArchivedDeaths(filter models.DeathFilter) ([]models.ArchivedDeath, error) {
+	// language=SQL
+	SQL := `SELECT dd.id,
+                   dd.name,
+                   coalesce(dd.phone_no, '') as phone_no,
+                   dd.age,
+                   dd.gender,
+                   coalesce(dd.aadhar_number, '') as aadhar_number,
+                   dd.date_of_death,
+                   dd.created_at,
+                   dd.created_by,
+                   gp.id as gram_panchayat_id,
+                   gp.name as gram_panchayat_name,
+                   g.id as gaon_id,
+                   g.name as gaon_name,
+                   dd.archived_at,
+                   dd.archived_by,
+                   u.name as archived_by_name,
+                   coalesce(dd.archive_reason, '') as archive_reason
+            FROM   death_details dd
+                   JOIN gram_panchayat gp on gp.id = dd.gram_panchayat_id
+                   JOIN gaon g on g.id = dd.gaon_id
+                   LEFT JOIN users u on u.id = dd.archived_by
+            WHERE  dd.archived_at IS NOT NULL `
+
+	values := make([]interface{}, 0)
+	num := 0
+
+	if len(filter.GramPanchayatID) > 0 {
+		SQL += fmt.Sprintf("AND dd.gram_panchayat_id =ANY($%d) ", num+1)
+		num++
+		values = append(values, pq.Array(filter.GramPanchayatID))
+	}
+	if len(filter.TehsilID) > 0 {
+		SQL += fmt.Sprintf("AND gp.tehsil_id =ANY($%d) ", num+1)
+		num++
+		values = append(values, pq.Array(filter.TehsilID))
+	}
+	if len(filter.BlockID) > 0 {
+		SQL += fmt.Sprintf("AND gp.block_id =ANY($%d) ", num+1)
+		num++
+		values = append(values, pq.Array(filter.BlockID))
+	}
+	if filter.Search != "" {
+		SQL += fmt.Sprintf("AND (dd.name ilike '%%' || $%d || '%%' OR dd.phone_no ilike '%%' || $%d || '%%') ", num+1, num+1)
+		num++
+		values = append(values, filter.Search)
+	}
+
+	scopeStr, num, values := jurisdictionClause(filter.Jurisdiction, "gp.tehsil_id", "gp.block_id", num, values)
+	SQL += scopeStr
+	SQL += fmt.Sprintf("ORDER BY dd.archived_at DESC, dd.id DESC LIMIT $%d OFFSET $%d", num+1, num+2)
+	values = append(values, filter.Limit, filter.Limit*filter.Page)
+
+	deaths := make([]models.ArchivedDeath, 0)
+
+	err := database.GramPanchayatDB.Select(&deaths, SQL, values...)
+	if err != nil {
+		logrus.Printf("GetArchivedDeaths: cannot get archived deaths:%v", err)
+		return deaths, err
+	}
+	return deaths, nil
+}
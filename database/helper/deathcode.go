@@ -0,0 +1,203 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+)
+
+var ErrDuplicateDeathCode = errors.New("code is already on the list")
+
+// deathCodeColumns are the death_details columns holding the code of every list
+var deathCodeColumns = map[string]string{
+	utilities.DeathCodeCause:        "cause_of_death",
+	utilities.DeathCodePlace:        "place_of_death",
+	utilities.DeathCodeCircumstance: "circumstance",
+}
+
+// GetDeathCodes returns the active codes of the list, every list when kind is empty
+func GetDeathCodes(kind string) ([]models.DeathCode, error) {
+	// language=SQL
+	SQL := `SELECT id, kind, code, name
+            FROM   death_code
+            WHERE  archived_at IS NULL
+            AND    ($1 = '' OR kind = $1)
+            ORDER BY kind, name`
+
+	codes := make([]models.DeathCode, 0)
+
+	err := database.GramPanchayatDB.Select(&codes, SQL, kind)
+	if err != nil {
+		logrus.Printf("GetDeathCodes: cannot get death codes:%v", err)
+		return codes, err
+	}
+	return codes, nil
+}
+
+func IsDeathCode(kind, code string) (bool, error) {
+	// language=SQL
+	SQL := `SELECT EXISTS(SELECT 1
+                          FROM   death_code
+                          WHERE  kind = $1
+                          AND    code = $2
+                          AND    archived_at IS NULL)`
+
+	var exists bool
+
+	err := database.GramPanchayatDB.Get(&exists, SQL, kind, code)
+	if err != nil {
+		logrus.Printf("IsDeathCode: cannot check death code:%v", err)
+		return false, err
+	}
+	return exists, nil
+}
+
+func AddDeathCode(code models.DeathCode, tx *sqlx.Tx) (int, error) {
+	// language=SQL
+	SQL := `INSERT INTO death_code(kind, code, name)
+            VALUES ($1, $2, $3)
+            RETURNING id`
+
+	var codeID int
+
+	err := tx.Get(&codeID, SQL, code.Kind, code.Code, code.Name)
+	if isUniqueViolation(err) {
+		return 0, ErrDuplicateDeathCode
+	}
+	if err != nil {
+		logrus.Printf("AddDeathCode: cannot add death code:%v", err)
+		return 0, err
+	}
+	return codeID, nil
+}
+
+// UpdateDeathCode only renames the code, the code itself is stored on the deaths registered with it
+func UpdateDeathCode(code models.DeathCode, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE death_code
+            SET    name = $2
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, code.ID, code.Name)
+	if err != nil {
+		logrus.Printf("UpdateDeathCode: cannot update death code:%v", err)
+		return err
+	}
+	return nil
+}
+
+func ArchiveDeathCode(codeID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE death_code
+            SET    archived_at = now()
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, codeID)
+	if err != nil {
+		logrus.Printf("ArchiveDeathCode: cannot archive death code:%v", err)
+		return err
+	}
+	return nil
+}
+
+// deathCodeClause filters the deaths on the codes picked in the filter, prefix is the alias of death_details
+func deathCodeClause(filter models.DeathFilter, prefix string, num int, values []interface{}) (string, int, []interface{}) {
+	clause := ""
+	for _, list := range []struct {
+		column string
+		codes  []string
+	}{
+		{deathCodeColumns[utilities.DeathCodeCause], filter.CauseOfDeath},
+		{deathCodeColumns[utilities.DeathCodePlace], filter.PlaceOfDeath},
+		{deathCodeColumns[utilities.DeathCodeCircumstance], filter.Circumstance},
+	} {
+		if len(list.codes) == 0 {
+			continue
+		}
+		clause += fmt.Sprintf("AND %s.%s =ANY($%d) ", prefix, list.column, num+1)
+		num++
+		values = append(values, pq.StringArray(list.codes))
+	}
+	return clause, num, values
+}
+
+// GetDeathBreakdown counts the deaths registered in the filter's dates and places by cause, place and circumstance
+func GetDeathBreakdown(filter models.DeathFilter) (models.DeathBreakdown, error) {
+	breakdown := models.DeathBreakdown{}
+
+	whereStr := ""
+	values := make([]interface{}, 0)
+	num := 0
+
+	if len(filter.GramPanchayatID) > 0 {
+		whereStr += fmt.Sprintf("AND dd.gram_panchayat_id =ANY($%d) ", num+1)
+		num++
+		values = append(values, pq.Array(filter.GramPanchayatID))
+	}
+	if len(filter.TehsilID) > 0 {
+		whereStr += fmt.Sprintf("AND gp.tehsil_id =ANY($%d) ", num+1)
+		num++
+		values = append(values, pq.Array(filter.TehsilID))
+	}
+	if len(filter.BlockID) > 0 {
+		whereStr += fmt.Sprintf("AND gp.block_id =ANY($%d) ", num+1)
+		num++
+		values = append(values, pq.Array(filter.BlockID))
+	}
+	if !filter.FromDate.IsZero() {
+		whereStr += fmt.Sprintf("AND dd.created_at::date >= $%d::date ", num+1)
+		num++
+		values = append(values, filter.FromDate)
+	}
+	if !filter.ToDate.IsZero() {
+		whereStr += fmt.Sprintf("AND dd.created_at::date <= $%d::date ", num+1)
+		num++
+		values = append(values, filter.ToDate)
+	}
+
+	codeStr, num, values := deathCodeClause(filter, "dd", num, values)
+	scopeStr, _, values := jurisdictionClause(filter.Jurisdiction, "gp.tehsil_id", "gp.block_id", num, values)
+	whereStr += codeStr + scopeStr
+
+	for _, list := range []struct {
+		kind   string
+		counts *[]models.DeathCodeCount
+	}{
+		{utilities.DeathCodeCause, &breakdown.Causes},
+		{utilities.DeathCodePlace, &breakdown.Places},
+		{utilities.DeathCodeCircumstance, &breakdown.Circumstances},
+	} {
+		// an archived code keeps its name on the deaths registered before it was archived
+		// language=SQL
+		SQL := fmt.Sprintf(`SELECT coalesce(dd.%[1]s, 'unknown') as code,
+                                   coalesce(dc.name, 'Unknown') as name,
+                                   count(*) as deaths
+                            FROM   death_details dd
+                                   JOIN gram_panchayat gp on gp.id = dd.gram_panchayat_id
+                                   LEFT JOIN LATERAL (SELECT name
+                                                      FROM   death_code
+                                                      WHERE  kind = '%[2]s'
+                                                      AND    code = dd.%[1]s
+                                                      ORDER BY archived_at DESC NULLS FIRST
+                                                      LIMIT 1) dc on true
+                            WHERE  dd.archived_at IS NULL
+                            %[3]s
+                            GROUP BY 1, 2
+                            ORDER BY deaths DESC, name`, deathCodeColumns[list.kind], list.kind, whereStr)
+
+		*list.counts = make([]models.DeathCodeCount, 0)
+		err := database.GramPanchayatDB.Select(list.counts, SQL, values...)
+		if err != nil {
+			logrus.Printf("GetDeathBreakdown: cannot count deaths by %s:%v", list.kind, err)
+			return breakdown, err
+		}
+	}
+	return breakdown, nil
+}
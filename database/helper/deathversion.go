@@ -0,0 +1,217 @@
+package helper
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+	"sort"
+)
+
+var ErrGaonOutsideGramPanchayat = errors.New("gaon is not in the gram panchayat the death is registered in")
+
+// AddDeathVersion keeps the details of the death as they are now as its next version
+func AddDeathVersion(deathID, editedBy int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `INSERT INTO death_version(death_id, version, details, edited_by)
+            SELECT dd.id,
+                   (SELECT coalesce(max(version), 0) + 1 FROM death_version WHERE death_id = dd.id),
+                   jsonb_build_object('name', dd.name, 'phoneNo', dd.phone_no, 'age', dd.age, 'gender', dd.gender,
+                                      'aadharNumber', dd.aadhar_number, 'address', a.address, 'dateOfDeath', dd.date_of_death,
+                                      'gaonId', dd.gaon_id, 'causeOfDeath', dd.cause_of_death, 'placeOfDeath', dd.place_of_death,
+                                      'circumstance', dd.circumstance, 'occupation', dd.occupation, 'isBpl', dd.is_bpl,
+                                      'schemes', dd.schemes),
+                   $2
+            FROM   death_details dd
+                   LEFT JOIN death_details_address dda on dda.death_detail_id = dd.id AND dda.archived_at IS NULL
+                   LEFT JOIN address a on a.id = dda.address_id
+            WHERE  dd.id = $1
+            LIMIT 1`
+
+	_, err := tx.Exec(SQL, deathID, editedBy)
+	if err != nil {
+		logrus.Printf("AddDeathVersion: cannot add death version:%v", err)
+		return err
+	}
+	return nil
+}
+
+// GetDeathCreatedBy returns sql.ErrNoRows when the death does not exist
+func GetDeathCreatedBy(deathID int) (int, error) {
+	// language=SQL
+	SQL := `SELECT coalesce(created_by, 0)
+            FROM   death_details
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	var createdBy int
+
+	err := database.GramPanchayatDB.Get(&createdBy, SQL, deathID)
+	return createdBy, err
+}
+
+// UpdateDeath corrects the details of the death and keeps the result as a new version.
+// The tasks of the death stay as they were decided at registration.
+func UpdateDeath(deathID, editedBy int, edit models.DeathEditRequest, tx *sqlx.Tx) error {
+	// the lock keeps two edits from taking the same version number
+	// language=SQL
+	SQL := `SELECT gram_panchayat_id
+            FROM   death_details
+            WHERE  id = $1
+            AND    archived_at IS NULL
+            FOR UPDATE`
+
+	var gramPanchayatID int
+
+	err := tx.Get(&gramPanchayatID, SQL, deathID)
+	if err != nil {
+		logrus.Printf("UpdateDeath: cannot get death:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `SELECT EXISTS(SELECT 1
+                         FROM   gaon
+                         WHERE  id = $1
+                         AND    gram_panchayat_id = $2)`
+
+	var isGaonInGramPanchayat bool
+
+	err = tx.Get(&isGaonInGramPanchayat, SQL, edit.GaonID, gramPanchayatID)
+	if err != nil {
+		logrus.Printf("UpdateDeath: cannot check gaon:%v", err)
+		return err
+	}
+	if !isGaonInGramPanchayat {
+		return ErrGaonOutsideGramPanchayat
+	}
+
+	// language=SQL
+	SQL = `UPDATE death_details
+           SET    name = $2,
+                  phone_no = $3,
+                  age = $4,
+                  gender = $5,
+                  aadhar_number = $6,
+                  date_of_death = $7,
+                  gaon_id = $8,
+                  cause_of_death = nullif($9, ''),
+                  place_of_death = nullif($10, ''),
+                  circumstance = nullif($11, ''),
+                  occupation = nullif($12, ''),
+                  is_bpl = $13,
+                  schemes = coalesce($14::text[], '{}'),
+                  updated_at = now()
+           WHERE  id = $1`
+
+	_, err = tx.Exec(SQL, deathID, edit.Name, edit.PhoneNo, edit.Age, edit.Gender, edit.AadharNumber, edit.DateOfDeath, edit.GaonID,
+		edit.CauseOfDeath, edit.PlaceOfDeath, edit.Circumstance, edit.Occupation, edit.IsBPL, pq.StringArray(edit.Schemes))
+	if err != nil {
+		logrus.Printf("UpdateDeath: cannot update death:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `UPDATE address
+           SET    address = $2,
+                  updated_at = now()
+           WHERE  id IN (SELECT address_id
+                         FROM   death_details_address
+                         WHERE  death_detail_id = $1
+                         AND    archived_at IS NULL)`
+
+	_, err = tx.Exec(SQL, deathID, edit.Address)
+	if err != nil {
+		logrus.Printf("UpdateDeath: cannot update address:%v", err)
+		return err
+	}
+
+	return AddDeathVersion(deathID, editedBy, tx)
+}
+
+// GetDeathVersions returns every version of the death, oldest first, each with what changed since the one before
+func GetDeathVersions(deathID int) ([]models.DeathVersion, error) {
+	// language=SQL
+	SQL := `SELECT v.version,
+                   v.details,
+                   v.edited_by,
+                   u.name as editor_name,
+                   v.edited_at
+            FROM   death_version v
+                   LEFT JOIN users u on u.id = v.edited_by
+            WHERE  v.death_id = $1
+            ORDER BY v.version`
+
+	rows, err := database.GramPanchayatDB.Queryx(SQL, deathID)
+	if err != nil {
+		logrus.Printf("GetDeathVersions: cannot get death versions:%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make([]models.DeathVersion, 0)
+	for rows.Next() {
+		var row struct {
+			models.DeathVersion
+			Details []byte `db:"details"`
+		}
+		err = rows.StructScan(&row)
+		if err != nil {
+			logrus.Printf("GetDeathVersions: cannot scan death version:%v", err)
+			return nil, err
+		}
+		row.DeathVersion.Details = row.Details
+		versions = append(versions, row.DeathVersion)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range versions {
+		versions[i].Changes = make([]models.DeathFieldChange, 0)
+		if i == 0 {
+			continue
+		}
+		versions[i].Changes, err = diffDeathVersions(versions[i-1].Details, versions[i].Details)
+		if err != nil {
+			logrus.Printf("GetDeathVersions: cannot compare versions:%v", err)
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+// diffDeathVersions lists the fields that differ between the two versions, in the order of their names
+func diffDeathVersions(before, after json.RawMessage) ([]models.DeathFieldChange, error) {
+	var from, to map[string]json.RawMessage
+	if err := json.Unmarshal(before, &from); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(after, &to); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(to))
+	for field := range to {
+		fields = append(fields, field)
+	}
+	for field := range from {
+		if _, ok := to[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := make([]models.DeathFieldChange, 0)
+	for _, field := range fields {
+		// jsonb prints the same value the same way, so the text can be compared
+		if string(from[field]) == string(to[field]) {
+			continue
+		}
+		changes = append(changes, models.DeathFieldChange{Field: field, From: from[field], To: to[field]})
+	}
+	return changes, nil
+}
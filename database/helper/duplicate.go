@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"strings"
+)
+
+var ErrDuplicateDeath = errors.New("death looks like one already registered")
+
+// duplicateNameSimilarity is how alike two names in the same gaon, dying around the same day, have to be to be the same person
+const duplicateNameSimilarity = 0.8
+
+// duplicate reasons of a candidate
+const (
+	DuplicateMatchAadhar       = "aadhar"
+	DuplicateMatchNameDateGaon = "name_date_gaon"
+)
+
+// FindDuplicateDeaths returns the registered deaths with the same aadhaar, or with a similar name in the same gaon
+// within a day of the date of death. Registrations are few, so they wait for each other to keep two of the same
+// person from slipping in side by side.
+func FindDuplicateDeaths(deathDetails models.DeathRegistrationRequest, tx *sqlx.Tx) ([]models.DuplicateCandidate, error) {
+	// language=SQL
+	SQL := `SELECT pg_advisory_xact_lock(hashtext('death-registration'))`
+
+	_, err := tx.Exec(SQL)
+	if err != nil {
+		logrus.Printf("FindDuplicateDeaths: cannot lock registrations:%v", err)
+		return nil, err
+	}
+
+	aadhar := strings.ReplaceAll(deathDetails.AadharNumber, " ", "")
+
+	// language=SQL
+	SQL = `SELECT dd.id,
+                  dd.name,
+                  coalesce(dd.aadhar_number, '') as aadhar_number,
+                  dd.date_of_death,
+                  dd.gaon_id,
+                  g.name as gaon_name,
+                  gp.name as gram_panchayat_name,
+                  coalesce(dd.created_by, 0) as created_by,
+                  dd.created_at,
+                  ($1 <> '' AND replace(dd.aadhar_number, ' ', '') = $1) as same_aadhar
+           FROM   death_details dd
+                  JOIN gaon g on g.id = dd.gaon_id
+                  JOIN gram_panchayat gp on gp.id = dd.gram_panchayat_id
+           WHERE  dd.archived_at IS NULL
+           AND    (($1 <> '' AND replace(dd.aadhar_number, ' ', '') = $1)
+                   OR (dd.gaon_id = $2 AND dd.date_of_death BETWEEN $3::timestamptz - '1 day'::interval AND $3::timestamptz + '1 day'::interval))
+           ORDER BY dd.created_at`
+
+	rows := make([]models.DuplicateCandidate, 0)
+
+	err = tx.Select(&rows, SQL, aadhar, deathDetails.GaonID, deathDetails.DateOfDeath)
+	if err != nil {
+		logrus.Printf("FindDuplicateDeaths: cannot get possible duplicates:%v", err)
+		return nil, err
+	}
+
+	candidates := make([]models.DuplicateCandidate, 0)
+	for _, candidate := range rows {
+		candidate.MatchedOn = make([]string, 0, 2)
+		if candidate.SameAadhar {
+			candidate.MatchedOn = append(candidate.MatchedOn, DuplicateMatchAadhar)
+		}
+		// the query also brings deaths of the gaon around the date, only a similar name makes them a duplicate
+		if candidate.GaonID == deathDetails.GaonID && utilities.NameSimilarity(candidate.Name, deathDetails.Name) >= duplicateNameSimilarity {
+			candidate.MatchedOn = append(candidate.MatchedOn, DuplicateMatchNameDateGaon)
+		}
+		if len(candidate.MatchedOn) > 0 {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates, nil
+}
+
+// MarkPossibleDuplicate keeps on a death registered with an override which deaths it looked like and why it was let through
+func MarkPossibleDuplicate(deathID int, candidates []models.DuplicateCandidate, justification string, tx *sqlx.Tx) error {
+	candidateIDs := make([]int, 0, len(candidates))
+	for _, candidate := range candidates {
+		candidateIDs = append(candidateIDs, candidate.ID)
+	}
+
+	// language=SQL
+	SQL := `UPDATE death_details
+            SET    possible_duplicate_ids = $2,
+                   duplicate_justification = $3
+            WHERE  id = $1`
+
+	_, err := tx.Exec(SQL, deathID, pq.Array(candidateIDs), justification)
+	if err != nil {
+		logrus.Printf("MarkPossibleDuplicate: cannot mark death:%v", err)
+		return err
+	}
+	return nil
+}
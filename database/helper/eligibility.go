@@ -0,0 +1,144 @@
+package helper
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/eligibility"
+	"grampanchayat/models"
+)
+
+// decideDeathTasks runs the eligibility rules of every task type against the death, records each decision
+// and returns the task types whose tasks have to be created
+func decideDeathTasks(deathID int, deathDetails models.DeathRegistrationRequest, tx *sqlx.Tx) ([]int, error) {
+	// language=SQL
+	SQL := `SELECT id
+            FROM   task_types
+            ORDER BY id`
+
+	taskTypeIDs := make([]int, 0)
+
+	err := tx.Select(&taskTypeIDs, SQL)
+	if err != nil {
+		logrus.Printf("decideDeathTasks: cannot get task types:%v", err)
+		return nil, err
+	}
+
+	// language=SQL
+	SQL = `SELECT id,
+                  task_type_id,
+                  attribute,
+                  operator,
+                  rule_values,
+                  coalesce(description, '') as description
+           FROM   task_type_eligibility_rule
+           WHERE  archived_at IS NULL
+           ORDER BY id`
+
+	rules := make([]models.EligibilityRule, 0)
+
+	err = tx.Select(&rules, SQL)
+	if err != nil {
+		logrus.Printf("decideDeathTasks: cannot get eligibility rules:%v", err)
+		return nil, err
+	}
+
+	rulesOfTaskType := make(map[int][]models.EligibilityRule)
+	for _, rule := range rules {
+		rulesOfTaskType[rule.TaskTypeID] = append(rulesOfTaskType[rule.TaskTypeID], rule)
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO task_eligibility(death_id, task_type_id, is_eligible, reasons)
+           VALUES ($1, $2, $3, $4)`
+
+	eligibleTaskTypeIDs := make([]int, 0, len(taskTypeIDs))
+	for _, taskTypeID := range taskTypeIDs {
+		isEligible, reasons := eligibility.Evaluate(rulesOfTaskType[taskTypeID], deathDetails)
+
+		_, err = tx.Exec(SQL, deathID, taskTypeID, isEligible, pq.StringArray(reasons))
+		if err != nil {
+			logrus.Printf("decideDeathTasks: cannot add task eligibility:%v", err)
+			return nil, err
+		}
+		if isEligible {
+			eligibleTaskTypeIDs = append(eligibleTaskTypeIDs, taskTypeID)
+		}
+	}
+	return eligibleTaskTypeIDs, nil
+}
+
+func GetEligibilityRules(taskTypeID int) ([]models.EligibilityRule, error) {
+	// language=SQL
+	SQL := `SELECT id,
+                   task_type_id,
+                   attribute,
+                   operator,
+                   rule_values,
+                   coalesce(description, '') as description
+            FROM   task_type_eligibility_rule
+            WHERE  task_type_id = $1
+            AND    archived_at IS NULL
+            ORDER BY id`
+
+	rules := make([]models.EligibilityRule, 0)
+
+	err := database.GramPanchayatDB.Select(&rules, SQL, taskTypeID)
+	if err != nil {
+		logrus.Printf("GetEligibilityRules: cannot get eligibility rules:%v", err)
+		return rules, err
+	}
+	return rules, nil
+}
+
+// SetEligibilityRules replaces the rules of the task type, deaths registered before keep the tasks they got
+func SetEligibilityRules(taskTypeID int, rules []models.EligibilityRule, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE task_type_eligibility_rule
+            SET    archived_at = now()
+            WHERE  task_type_id = $1
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, taskTypeID)
+	if err != nil {
+		logrus.Printf("SetEligibilityRules: cannot archive eligibility rules:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO task_type_eligibility_rule(task_type_id, attribute, operator, rule_values, description)
+           VALUES ($1, $2, $3, $4, nullif($5, ''))`
+
+	for _, rule := range rules {
+		_, err = tx.Exec(SQL, taskTypeID, rule.Attribute, rule.Operator, rule.Values, rule.Description)
+		if err != nil {
+			logrus.Printf("SetEligibilityRules: cannot add eligibility rule:%v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDeathEligibility returns why each task type was or was not created for the death
+func GetDeathEligibility(deathID int) ([]models.TaskEligibility, error) {
+	// language=SQL
+	SQL := `SELECT te.task_type_id,
+                   tt.name as task_type_name,
+                   te.is_eligible,
+                   te.reasons,
+                   te.created_at
+            FROM   task_eligibility te
+                   JOIN task_types tt on tt.id = te.task_type_id
+            WHERE  te.death_id = $1
+            ORDER BY tt.name`
+
+	decisions := make([]models.TaskEligibility, 0)
+
+	err := database.GramPanchayatDB.Select(&decisions, SQL, deathID)
+	if err != nil {
+		logrus.Printf("GetDeathEligibility: cannot get task eligibility:%v", err)
+		return decisions, err
+	}
+	return decisions, nil
+}
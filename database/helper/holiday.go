@@ -0,0 +1,84 @@
+package helper
+
+import (
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"time"
+)
+
+var ErrDuplicateHoliday = errors.New("date is already a holiday")
+
+// isUniqueViolation tells whether the insert or update hit a unique index
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func GetHolidays() ([]models.Holiday, error) {
+	// language=SQL
+	SQL := `SELECT id, date, name
+            FROM   holiday
+            WHERE  archived_at IS NULL
+            ORDER BY date`
+
+	holidays := make([]models.Holiday, 0)
+
+	err := database.GramPanchayatDB.Select(&holidays, SQL)
+	if err != nil {
+		logrus.Printf("GetHolidays: cannot get holidays:%v", err)
+		return holidays, err
+	}
+	return holidays, nil
+}
+
+// GetHolidayCalendar returns the holidays the way utilities.DueDate skips them
+func GetHolidayCalendar() (utilities.Holidays, error) {
+	holidays, err := GetHolidays()
+	if err != nil {
+		return nil, err
+	}
+	dates := make([]time.Time, 0, len(holidays))
+	for _, holiday := range holidays {
+		dates = append(dates, holiday.Date)
+	}
+	return utilities.NewHolidays(dates), nil
+}
+
+func AddHoliday(holiday models.Holiday, tx *sqlx.Tx) (int, error) {
+	// language=SQL
+	SQL := `INSERT INTO holiday(date, name)
+            VALUES ($1, $2)
+            RETURNING id`
+
+	var holidayID int
+
+	err := tx.Get(&holidayID, SQL, holiday.Date, holiday.Name)
+	if isUniqueViolation(err) {
+		return 0, ErrDuplicateHoliday
+	}
+	if err != nil {
+		logrus.Printf("AddHoliday: cannot add holiday:%v", err)
+		return 0, err
+	}
+	return holidayID, nil
+}
+
+func ArchiveHoliday(holidayID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE holiday
+            SET    archived_at = now()
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, holidayID)
+	if err != nil {
+		logrus.Printf("ArchiveHoliday: cannot archive holiday:%v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package helper
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+)
+
+// GetJurisdiction returns the tehsils and blocks the user is posted to,
+// roles with the district scope permission are not limited at all
+func GetJurisdiction(userID int, role string) (models.Jurisdiction, error) {
+	jurisdiction := models.Jurisdiction{
+		TehsilIDs: make([]int, 0),
+		BlockIDs:  make([]int, 0),
+	}
+
+	isDistrict, err := HasPermission(role, utilities.PermissionScopeDistrict)
+	if err != nil {
+		return jurisdiction, err
+	}
+	if isDistrict {
+		jurisdiction.IsDistrict = true
+		return jurisdiction, nil
+	}
+
+	// language=SQL
+	SQL := `SELECT tehsil_id
+            FROM   user_tehsil
+            WHERE  user_id = $1
+            AND    archived_at IS NULL`
+
+	err = database.GramPanchayatDB.Select(&jurisdiction.TehsilIDs, SQL, userID)
+	if err != nil {
+		logrus.Printf("GetJurisdiction: cannot get tehsils of user:%v", err)
+		return jurisdiction, err
+	}
+
+	// language=SQL
+	SQL = `SELECT block_id
+           FROM   user_block
+           WHERE  user_id = $1
+           AND    archived_at IS NULL`
+
+	err = database.GramPanchayatDB.Select(&jurisdiction.BlockIDs, SQL, userID)
+	if err != nil {
+		logrus.Printf("GetJurisdiction: cannot get blocks of user:%v", err)
+		return jurisdiction, err
+	}
+	return jurisdiction, nil
+}
+
+// jurisdictionClause limits a death query to the caller's tehsils or blocks, it adds nothing for district wide users
+func jurisdictionClause(jurisdiction models.Jurisdiction, tehsilColumn, blockColumn string, num int, values []interface{}) (string, int, []interface{}) {
+	if jurisdiction.IsDistrict {
+		return "", num, values
+	}
+	clause := fmt.Sprintf("AND (%s =ANY($%d) OR %s =ANY($%d)) ", tehsilColumn, num+1, blockColumn, num+2)
+	values = append(values, pq.Array(jurisdiction.TehsilIDs), pq.Array(jurisdiction.BlockIDs))
+	return clause, num + 2, values
+}
+
+func AddUserBlock(userID, blockID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `INSERT INTO user_block(user_id, block_id)
+            VALUES ($1, $2)`
+
+	_, err := tx.Exec(SQL, userID, blockID)
+	if err != nil {
+		logrus.Printf("AddUserBlock: cannot add user_block:%v", err)
+		return err
+	}
+	return nil
+}
+
+func IsDeathInJurisdiction(deathID int, jurisdiction models.Jurisdiction) (bool, error) {
+	// language=SQL
+	SQL := `SELECT EXISTS(SELECT 1
+                          FROM   death_details
+                                 JOIN gram_panchayat gp on death_details.gram_panchayat_id = gp.id
+                          WHERE  death_details.id = $1 `
+
+	values := []interface{}{deathID}
+	scopeStr, _, values := jurisdictionClause(jurisdiction, "gp.tehsil_id", "gp.block_id", len(values), values)
+	SQL += scopeStr + ")"
+
+	var inJurisdiction bool
+
+	err := database.GramPanchayatDB.Get(&inJurisdiction, SQL, values...)
+	if err != nil {
+		logrus.Printf("IsDeathInJurisdiction: cannot check jurisdiction:%v", err)
+		return false, err
+	}
+	return inJurisdiction, nil
+}
+
+// CanAccessDeath tells whether the death is posted to the user at any level, from the gaon up to the district.
+// It returns sql.ErrNoRows when the death does not exist.
+func CanAccessDeath(deathID, userID int) (bool, error) {
+	// language=SQL
+	SQL := `SELECT (r.is_district_level OR ugp.id IS NOT NULL OR ut.id IS NOT NULL OR ug.id IS NOT NULL OR ub.id IS NOT NULL) as in_jurisdiction
+            FROM   death_details dd
+                   JOIN gram_panchayat gp on dd.gram_panchayat_id = gp.id
+                   JOIN users on users.id = $2
+                   JOIN roles r on users.roles_id = r.id
+                   LEFT JOIN user_gram_panchayat ugp on dd.gram_panchayat_id = ugp.gram_panchayat_id and users.id = ugp.user_id
+                   LEFT JOIN user_tehsil ut on gp.tehsil_id = ut.tehsil_id and users.id = ut.user_id
+                   LEFT JOIN user_gaon ug on dd.gaon_id = ug.gaon_id and users.id = ug.user_id
+                   LEFT JOIN user_block ub on gp.block_id = ub.block_id and users.id = ub.user_id and ub.archived_at IS NULL
+            WHERE  dd.id = $1
+            AND    dd.archived_at IS NULL
+            LIMIT 1`
+
+	var inJurisdiction bool
+
+	err := database.GramPanchayatDB.Get(&inJurisdiction, SQL, deathID, userID)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Printf("CanAccessDeath: cannot check death access:%v", err)
+	}
+	return inJurisdiction, err
+}
+
+// IsUserInJurisdiction tells whether the official is posted to one of the tehsils or blocks, or to a gram panchayat in them
+func IsUserInJurisdiction(userID int, jurisdiction models.Jurisdiction) (bool, error) {
+	if jurisdiction.IsDistrict {
+		return true, nil
+	}
+
+	// language=SQL
+	SQL := `SELECT EXISTS(SELECT 1
+                          FROM   user_tehsil
+                          WHERE  user_id = $1
+                          AND    archived_at IS NULL
+                          AND    tehsil_id =ANY($2))
+                   OR EXISTS(SELECT 1
+                             FROM   user_block
+                             WHERE  user_id = $1
+                             AND    archived_at IS NULL
+                             AND    block_id =ANY($3))
+                   OR EXISTS(SELECT 1
+                             FROM   user_gram_panchayat ugp
+                                    JOIN gram_panchayat gp on gp.id = ugp.gram_panchayat_id
+                             WHERE  ugp.user_id = $1
+                             AND    ugp.archived_at IS NULL
+                             AND    (gp.tehsil_id =ANY($2) OR gp.block_id =ANY($3)))`
+
+	var inJurisdiction bool
+
+	err := database.GramPanchayatDB.Get(&inJurisdiction, SQL, userID, pq.Array(jurisdiction.TehsilIDs), pq.Array(jurisdiction.BlockIDs))
+	if err != nil {
+		logrus.Printf("IsUserInJurisdiction: cannot check jurisdiction:%v", err)
+		return false, err
+	}
+	return inJurisdiction, nil
+}
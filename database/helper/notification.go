@@ -0,0 +1,61 @@
+package helper
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+)
+
+func AddNotification(userID int, message string, taskID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `INSERT INTO notification(user_id, message, task_id)
+            VALUES ($1, $2, nullif($3, 0))`
+
+	_, err := tx.Exec(SQL, userID, message, taskID)
+	if err != nil {
+		logrus.Printf("AddNotification: cannot add notification:%v", err)
+		return err
+	}
+	return nil
+}
+
+func GetNotifications(userID int, unreadOnly bool) ([]models.Notification, error) {
+	// language=SQL
+	SQL := `SELECT id,
+                   message,
+                   task_id,
+                   created_at,
+                   read_at
+            FROM   notification
+            WHERE  user_id = $1
+            AND    (NOT $2 OR read_at IS NULL)
+            ORDER BY created_at DESC
+            LIMIT 100`
+
+	notifications := make([]models.Notification, 0)
+
+	err := database.GramPanchayatDB.Select(&notifications, SQL, userID, unreadOnly)
+	if err != nil {
+		logrus.Printf("GetNotifications: cannot get notifications:%v", err)
+		return notifications, err
+	}
+	return notifications, nil
+}
+
+// MarkNotificationRead returns false when the notification is not the user's
+func MarkNotificationRead(notificationID, userID int) (bool, error) {
+	// language=SQL
+	SQL := `UPDATE notification
+            SET    read_at = coalesce(read_at, now())
+            WHERE  id = $1
+            AND    user_id = $2`
+
+	result, err := database.GramPanchayatDB.Exec(SQL, notificationID, userID)
+	if err != nil {
+		logrus.Printf("MarkNotificationRead: cannot mark notification read:%v", err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
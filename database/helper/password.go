@@ -0,0 +1,189 @@
+package helper
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+	"time"
+)
+
+func FetchAdminAuthDetails(phone string) (models.AdminAuthDetails, error) {
+	// language=SQL
+	SQL := `SELECT users.id,
+                   r.role,
+                   password_hash,
+                   totp_secret,
+                   totp_pending_secret,
+                   totp_enabled,
+                   failed_password_attempts,
+                   locked_until
+            FROM   users JOIN roles r on r.id = users.roles_id
+            WHERE  phone_no = $1
+            AND    users.archived_at IS NULL`
+
+	var details models.AdminAuthDetails
+
+	err := database.GramPanchayatDB.Get(&details, SQL, phone)
+	if err != nil {
+		logrus.Printf("FetchAdminAuthDetails: cannot fetch user:%v", err)
+		return details, err
+	}
+	return details, nil
+}
+
+func FetchAdminAuthDetailsByID(userID int) (models.AdminAuthDetails, error) {
+	// language=SQL
+	SQL := `SELECT users.id,
+                   r.role,
+                   password_hash,
+                   totp_secret,
+                   totp_pending_secret,
+                   totp_enabled,
+                   failed_password_attempts,
+                   locked_until
+            FROM   users JOIN roles r on r.id = users.roles_id
+            WHERE  users.id = $1
+            AND    users.archived_at IS NULL`
+
+	var details models.AdminAuthDetails
+
+	err := database.GramPanchayatDB.Get(&details, SQL, userID)
+	if err != nil {
+		logrus.Printf("FetchAdminAuthDetailsByID: cannot fetch user:%v", err)
+		return details, err
+	}
+	return details, nil
+}
+
+// RecordFailedPasswordAttempt counts a wrong password and locks the account for lockout once maxAttempts is reached
+func RecordFailedPasswordAttempt(userID, maxAttempts int, lockout time.Duration) error {
+	// language=SQL
+	SQL := `UPDATE users
+            SET    failed_password_attempts = failed_password_attempts + 1,
+                   locked_until = CASE WHEN failed_password_attempts + 1 >= $2 THEN $3 ELSE locked_until END
+            WHERE  id = $1`
+
+	_, err := database.GramPanchayatDB.Exec(SQL, userID, maxAttempts, time.Now().Add(lockout))
+	if err != nil {
+		logrus.Printf("RecordFailedPasswordAttempt: cannot update attempts:%v", err)
+		return err
+	}
+	return nil
+}
+
+func ResetFailedPasswordAttempts(userID int) error {
+	// language=SQL
+	SQL := `UPDATE users
+            SET    failed_password_attempts = 0,
+                   locked_until = NULL
+            WHERE  id = $1`
+
+	_, err := database.GramPanchayatDB.Exec(SQL, userID)
+	if err != nil {
+		logrus.Printf("ResetFailedPasswordAttempts: cannot reset attempts:%v", err)
+		return err
+	}
+	return nil
+}
+
+func UpdatePassword(userID int, passwordHash string, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE users
+            SET    password_hash = $2,
+                   password_changed_at = now(),
+                   failed_password_attempts = 0,
+                   locked_until = NULL,
+                   updated_at = now()
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, userID, passwordHash)
+	if err != nil {
+		logrus.Printf("UpdatePassword: cannot update password:%v", err)
+		return err
+	}
+	return nil
+}
+
+func CreatePasswordReset(userID int, tokenHash string, expiresAt time.Time, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `INSERT INTO password_reset(user_id, token_hash, expires_at)
+            VALUES ($1, $2, $3)`
+
+	_, err := tx.Exec(SQL, userID, tokenHash, expiresAt)
+	if err != nil {
+		logrus.Printf("CreatePasswordReset: cannot create password reset:%v", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumePasswordReset marks the reset token used and returns its user, sql.ErrNoRows if it is unknown, used or expired
+func ConsumePasswordReset(tokenHash string, tx *sqlx.Tx) (int, error) {
+	// language=SQL
+	SQL := `UPDATE password_reset
+            SET    used_at = now()
+            WHERE  token_hash = $1
+            AND    used_at IS NULL
+            AND    expires_at > now()
+            RETURNING user_id`
+
+	var userID int
+
+	err := tx.Get(&userID, SQL, tokenHash)
+	if err != nil {
+		logrus.Printf("ConsumePasswordReset: cannot consume password reset:%v", err)
+		return userID, err
+	}
+	return userID, nil
+}
+
+// SetPendingTOTPSecret keeps a new secret aside, totp keeps using the current one until EnableTOTP confirms it
+func SetPendingTOTPSecret(userID int, secret string, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE users
+            SET    totp_pending_secret = $2
+            WHERE  id = $1`
+
+	_, err := tx.Exec(SQL, userID, secret)
+	if err != nil {
+		logrus.Printf("SetPendingTOTPSecret: cannot set pending totp secret:%v", err)
+		return err
+	}
+	return nil
+}
+
+// EnablePendingTOTPSecret makes the pending secret the one totp checks codes against
+func EnablePendingTOTPSecret(userID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE users
+            SET    totp_secret = totp_pending_secret,
+                   totp_pending_secret = NULL,
+                   totp_enabled = true
+            WHERE  id = $1
+            AND    totp_pending_secret IS NOT NULL`
+
+	_, err := tx.Exec(SQL, userID)
+	if err != nil {
+		logrus.Printf("EnablePendingTOTPSecret: cannot enable totp:%v", err)
+		return err
+	}
+	return nil
+}
+
+func DisableTOTP(userID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE users
+            SET    totp_enabled = false,
+                   totp_secret = NULL,
+                   totp_pending_secret = NULL
+            WHERE  id = $1`
+
+	_, err := tx.Exec(SQL, userID)
+	if err != nil {
+		logrus.Printf("DisableTOTP: cannot disable totp:%v", err)
+		return err
+	}
+	return nil
+}
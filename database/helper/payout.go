@@ -0,0 +1,308 @@
+package helper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+)
+
+var (
+	ErrBeneficiaryNotOnDeath     = errors.New("beneficiary is not nominated for the death of the task")
+	ErrDuplicateSanction         = errors.New("beneficiary already has a sanction for the task")
+	ErrPayoutExceedsSanction     = errors.New("payment is more than the pending amount of the sanction")
+	ErrDuplicatePaymentReference = errors.New("payment reference is already recorded")
+	ErrTaskNotMonetary           = errors.New("task type is not monetary, nothing is sanctioned for it")
+	ErrSanctionBelowDisbursed    = errors.New("sanctioned amount cannot be less than what is already paid")
+	ErrSanctionHasPayouts        = errors.New("sanction has payments against it, correct it instead")
+)
+
+// AddSanction records the amount sanctioned to a beneficiary of the task's death, the task has to be of a monetary type
+func AddSanction(taskID int, sanction models.SanctionRequest, createdBy int, tx *sqlx.Tx) (int, error) {
+	// language=SQL
+	SQL := `SELECT tt.is_monetary
+            FROM   task t
+                   JOIN task_types tt on tt.id = t.task_type_id
+            WHERE  t.id = $1`
+
+	var isMonetary bool
+
+	err := tx.Get(&isMonetary, SQL, taskID)
+	if err != nil {
+		logrus.Printf("AddSanction: cannot get task type:%v", err)
+		return 0, err
+	}
+	if !isMonetary {
+		return 0, ErrTaskNotMonetary
+	}
+
+	// language=SQL
+	SQL = `SELECT EXISTS(SELECT 1
+                         FROM   task t
+                                JOIN beneficiary b on b.death_id = t.death_id
+                         WHERE  t.id = $1
+                         AND    b.id = $2
+                         AND    b.archived_at IS NULL)`
+
+	var isBeneficiary bool
+
+	err = tx.Get(&isBeneficiary, SQL, taskID, sanction.BeneficiaryID)
+	if err != nil {
+		logrus.Printf("AddSanction: cannot check beneficiary:%v", err)
+		return 0, err
+	}
+	if !isBeneficiary {
+		return 0, ErrBeneficiaryNotOnDeath
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO task_sanction(task_id, beneficiary_id, sanctioned_paise, sanction_order_no, sanctioned_on, created_by)
+           VALUES ($1, $2, $3, $4, $5, $6)
+           RETURNING id`
+
+	var sanctionID int
+
+	err = tx.Get(&sanctionID, SQL, taskID, sanction.BeneficiaryID, sanction.SanctionedPaise, sanction.SanctionOrderNo, sanction.SanctionedOn, createdBy)
+	if isUniqueViolation(err) {
+		return 0, ErrDuplicateSanction
+	}
+	if err != nil {
+		logrus.Printf("AddSanction: cannot add sanction:%v", err)
+		return 0, err
+	}
+	return sanctionID, nil
+}
+
+// lockSanction returns the sanctioned and already paid amounts of a live sanction and keeps payments and corrections
+// off it until the transaction ends
+func lockSanction(sanctionID int, tx *sqlx.Tx) (int64, int64, error) {
+	// language=SQL
+	SQL := `SELECT sanctioned_paise
+            FROM   task_sanction
+            WHERE  id = $1
+            AND    archived_at IS NULL
+            FOR UPDATE`
+
+	var sanctionedPaise int64
+
+	err := tx.Get(&sanctionedPaise, SQL, sanctionID)
+	if err != nil {
+		logrus.Printf("lockSanction: cannot get sanction:%v", err)
+		return 0, 0, err
+	}
+
+	// language=SQL
+	SQL = `SELECT coalesce(sum(amount_paise), 0)
+           FROM   payout
+           WHERE  sanction_id = $1`
+
+	var disbursedPaise int64
+
+	err = tx.Get(&disbursedPaise, SQL, sanctionID)
+	if err != nil {
+		logrus.Printf("lockSanction: cannot get disbursed amount:%v", err)
+		return 0, 0, err
+	}
+	return sanctionedPaise, disbursedPaise, nil
+}
+
+// EditSanction corrects the amount, order number and date of a sanction, the beneficiary stays as it was.
+// The amount cannot go below what is already paid of it.
+func EditSanction(sanctionID int, sanction models.SanctionRequest, tx *sqlx.Tx) error {
+	_, disbursedPaise, err := lockSanction(sanctionID, tx)
+	if err != nil {
+		return err
+	}
+	if sanction.SanctionedPaise < disbursedPaise {
+		return ErrSanctionBelowDisbursed
+	}
+
+	// language=SQL
+	SQL := `UPDATE task_sanction
+            SET    sanctioned_paise = $2,
+                   sanction_order_no = $3,
+                   sanctioned_on = $4,
+                   updated_at = now()
+            WHERE  id = $1`
+
+	_, err = tx.Exec(SQL, sanctionID, sanction.SanctionedPaise, sanction.SanctionOrderNo, sanction.SanctionedOn)
+	if err != nil {
+		logrus.Printf("EditSanction: cannot update sanction:%v", err)
+		return err
+	}
+	return nil
+}
+
+// ArchiveSanction takes a sanction recorded by mistake off the ledger, one with payments against it is only corrected
+func ArchiveSanction(sanctionID, archivedBy int, reason string, tx *sqlx.Tx) error {
+	_, disbursedPaise, err := lockSanction(sanctionID, tx)
+	if err != nil {
+		return err
+	}
+	if disbursedPaise > 0 {
+		return ErrSanctionHasPayouts
+	}
+
+	// language=SQL
+	SQL := `UPDATE task_sanction
+            SET    archived_at = now(),
+                   archived_by = $2,
+                   archive_reason = $3
+            WHERE  id = $1`
+
+	_, err = tx.Exec(SQL, sanctionID, archivedBy, reason)
+	if err != nil {
+		logrus.Printf("ArchiveSanction: cannot archive sanction:%v", err)
+		return err
+	}
+	return nil
+}
+
+// AddPayout records a payment against the sanction, the sanction is locked so two payments cannot both fit in what is pending
+func AddPayout(sanctionID int, payout models.PayoutRequest, createdBy int, tx *sqlx.Tx) (int, error) {
+	sanctionedPaise, disbursedPaise, err := lockSanction(sanctionID, tx)
+	if err != nil {
+		return 0, err
+	}
+	if disbursedPaise+payout.AmountPaise > sanctionedPaise {
+		return 0, ErrPayoutExceedsSanction
+	}
+
+	// language=SQL
+	SQL := `INSERT INTO payout(sanction_id, amount_paise, paid_on, payment_reference, created_by)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING id`
+
+	var payoutID int
+
+	err = tx.Get(&payoutID, SQL, sanctionID, payout.AmountPaise, payout.PaidOn, payout.PaymentReference, createdBy)
+	if isUniqueViolation(err) {
+		return 0, ErrDuplicatePaymentReference
+	}
+	if err != nil {
+		logrus.Printf("AddPayout: cannot add payout:%v", err)
+		return 0, err
+	}
+	return payoutID, nil
+}
+
+// GetSanctionTaskID returns sql.ErrNoRows when the sanction does not exist
+func GetSanctionTaskID(sanctionID int) (int, error) {
+	// language=SQL
+	SQL := `SELECT task_id
+            FROM   task_sanction
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	var taskID int
+
+	err := database.GramPanchayatDB.Get(&taskID, SQL, sanctionID)
+	return taskID, err
+}
+
+// GetTaskLedger returns the sanctions of the task with every payment made against them
+func GetTaskLedger(taskID int) ([]models.Sanction, error) {
+	// language=SQL
+	SQL := `SELECT s.id,
+                   s.task_id,
+                   s.beneficiary_id,
+                   b.name as beneficiary_name,
+                   s.sanction_order_no,
+                   s.sanctioned_on,
+                   s.sanctioned_paise,
+                   coalesce(sum(p.amount_paise), 0) as disbursed_paise,
+                   coalesce(json_agg(json_build_object('id', p.id, 'sanctionId', p.sanction_id, 'amountPaise', p.amount_paise,
+                                                       'paidOn', p.paid_on::timestamptz, 'paymentReference', p.payment_reference,
+                                                       'createdBy', p.created_by, 'createdAt', p.created_at)
+                                     ORDER BY p.paid_on, p.id) filter (where p.id IS NOT NULL), '[]') as payouts,
+                   s.created_by,
+                   s.created_at
+            FROM   task_sanction s
+                   JOIN beneficiary b on b.id = s.beneficiary_id
+                   LEFT JOIN payout p on p.sanction_id = s.id
+            WHERE  s.task_id = $1
+            AND    s.archived_at IS NULL
+            GROUP BY s.id, b.name
+            ORDER BY s.sanctioned_on, s.id`
+
+	rows, err := database.GramPanchayatDB.Queryx(SQL, taskID)
+	if err != nil {
+		logrus.Printf("GetTaskLedger: cannot get ledger:%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sanctions := make([]models.Sanction, 0)
+	for rows.Next() {
+		var row struct {
+			models.Sanction
+			Payouts []byte `db:"payouts"`
+		}
+		err = rows.StructScan(&row)
+		if err != nil {
+			logrus.Printf("GetTaskLedger: cannot scan sanction:%v", err)
+			return nil, err
+		}
+		err = json.Unmarshal(row.Payouts, &row.Sanction.Payouts)
+		if err != nil {
+			return nil, err
+		}
+		row.Sanction.PendingPaise = row.SanctionedPaise - row.DisbursedPaise
+		sanctions = append(sanctions, row.Sanction)
+	}
+	return sanctions, rows.Err()
+}
+
+// GetPayoutTotals sums the sanctions of each tehsil in the jurisdiction, the dates filter on the day of sanction
+func GetPayoutTotals(filter models.PayoutFilter, jurisdiction models.Jurisdiction) ([]models.PayoutTotals, error) {
+	// language=SQL
+	SQL := `SELECT t2.id as tehsil_id,
+                   t2.name as tehsil_name,
+                   count(s.id) as sanctions,
+                   coalesce(sum(s.sanctioned_paise), 0) as sanctioned_paise,
+                   coalesce(sum(p.disbursed_paise), 0) as disbursed_paise
+            FROM   task_sanction s
+                   JOIN task t on t.id = s.task_id
+                   JOIN death_details dd on dd.id = t.death_id
+                   JOIN gram_panchayat gp on gp.id = dd.gram_panchayat_id
+                   JOIN tehsil t2 on t2.id = gp.tehsil_id
+                   LEFT JOIN LATERAL (SELECT sum(amount_paise) as disbursed_paise
+                                      FROM   payout
+                                      WHERE  sanction_id = s.id) p on true
+            WHERE  s.archived_at IS NULL
+            AND    t.archived_at IS NULL
+            AND    dd.archived_at IS NULL `
+
+	values := make([]interface{}, 0)
+	num := 0
+
+	if !filter.FromDate.IsZero() {
+		SQL += fmt.Sprintf("AND s.sanctioned_on >= $%d ", num+1)
+		num++
+		values = append(values, filter.FromDate)
+	}
+
+	if !filter.ToDate.IsZero() {
+		SQL += fmt.Sprintf("AND s.sanctioned_on <= $%d ", num+1)
+		num++
+		values = append(values, filter.ToDate)
+	}
+
+	scopeStr, _, values := jurisdictionClause(jurisdiction, "gp.tehsil_id", "gp.block_id", num, values)
+	SQL += scopeStr + "GROUP BY t2.id, t2.name ORDER BY t2.name"
+
+	totals := make([]models.PayoutTotals, 0)
+
+	err := database.GramPanchayatDB.Select(&totals, SQL, values...)
+	if err != nil {
+		logrus.Printf("GetPayoutTotals: cannot get payout totals:%v", err)
+		return totals, err
+	}
+	for i := range totals {
+		totals[i].PendingPaise = totals[i].SanctionedPaise - totals[i].DisbursedPaise
+	}
+	return totals, nil
+}
@@ -0,0 +1,164 @@
+package helper
+
+import (
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+)
+
+var (
+	ErrPermissionManageOwnRole  = errors.New("cannot take permission:manage away from your own role")
+	ErrPermissionManageLastRole = errors.New("cannot take permission:manage away from the last role holding it")
+)
+
+func HasPermission(role, permission string) (bool, error) {
+	// language=SQL
+	SQL := `SELECT EXISTS(SELECT 1
+                          FROM   role_permissions rp
+                                 JOIN roles r on r.id = rp.role_id
+                                 JOIN permissions p on p.id = rp.permission_id
+                          WHERE  r.role = $1
+                          AND    p.name = $2
+                          AND    rp.archived_at IS NULL
+                          AND    p.archived_at IS NULL)`
+
+	var allowed bool
+
+	err := database.GramPanchayatDB.Get(&allowed, SQL, role, permission)
+	if err != nil {
+		logrus.Printf("HasPermission: cannot check permission:%v", err)
+		return false, err
+	}
+	return allowed, nil
+}
+
+func GetPermissions() ([]models.Permission, error) {
+	// language=SQL
+	SQL := `SELECT p.id,
+                   p.name,
+                   coalesce(p.description, '') as description,
+                   array_remove(array_agg(r.role ORDER BY r.role), NULL) as roles
+            FROM   permissions p
+                   LEFT JOIN role_permissions rp on p.id = rp.permission_id AND rp.archived_at IS NULL
+                   LEFT JOIN roles r on r.id = rp.role_id
+            WHERE  p.archived_at IS NULL
+            GROUP BY p.id, p.name, p.description
+            ORDER BY p.name`
+
+	permissions := make([]models.Permission, 0)
+
+	err := database.GramPanchayatDB.Select(&permissions, SQL)
+	if err != nil {
+		logrus.Printf("GetPermissions: cannot get permissions:%v", err)
+		return permissions, err
+	}
+	return permissions, nil
+}
+
+// GetUnknownPermissions returns the names that are not permissions, in the order given
+func GetUnknownPermissions(names []string) ([]string, error) {
+	// language=SQL
+	SQL := `SELECT n.name
+            FROM   unnest($1::text[]) WITH ORDINALITY as n(name, position)
+            WHERE  NOT EXISTS(SELECT 1
+                              FROM   permissions p
+                              WHERE  p.name = n.name
+                              AND    p.archived_at IS NULL)
+            ORDER BY n.position`
+
+	unknown := make([]string, 0)
+
+	err := database.GramPanchayatDB.Select(&unknown, SQL, pq.StringArray(names))
+	if err != nil {
+		logrus.Printf("GetUnknownPermissions: cannot check permissions:%v", err)
+		return unknown, err
+	}
+	return unknown, nil
+}
+
+// checkPermissionManageKept refuses to take permission:manage away from the caller's own role or from the last role
+// holding it, either would leave nobody able to give it back
+func checkPermissionManageKept(roleID int, callerRole string, tx *sqlx.Tx) error {
+	// the lock keeps two roles from giving it up at the same time
+	// language=SQL
+	SQL := `SELECT r.id,
+                   r.role
+            FROM   role_permissions rp
+                   JOIN roles r on r.id = rp.role_id
+                   JOIN permissions p on p.id = rp.permission_id
+            WHERE  p.name = $1
+            AND    rp.archived_at IS NULL
+            AND    p.archived_at IS NULL
+            FOR UPDATE OF rp`
+
+	holders := make([]struct {
+		ID   int    `db:"id"`
+		Role string `db:"role"`
+	}, 0)
+
+	err := tx.Select(&holders, SQL, utilities.PermissionPermissionManage)
+	if err != nil {
+		logrus.Printf("checkPermissionManageKept: cannot get roles holding permission:manage:%v", err)
+		return err
+	}
+
+	for _, holder := range holders {
+		if holder.ID != roleID {
+			continue
+		}
+		if holder.Role == callerRole {
+			return ErrPermissionManageOwnRole
+		}
+		if len(holders) == 1 {
+			return ErrPermissionManageLastRole
+		}
+	}
+	return nil
+}
+
+// SetRolePermissions replaces every permission of the role with the given list, the names are expected to be known,
+// see GetUnknownPermissions
+func SetRolePermissions(roleID int, permissions []string, callerRole string, tx *sqlx.Tx) error {
+	keepsPermissionManage := false
+	for _, permission := range permissions {
+		if permission == utilities.PermissionPermissionManage {
+			keepsPermissionManage = true
+		}
+	}
+	if !keepsPermissionManage {
+		err := checkPermissionManageKept(roleID, callerRole, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// language=SQL
+	SQL := `UPDATE role_permissions
+            SET    archived_at = now()
+            WHERE  role_id = $1
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, roleID)
+	if err != nil {
+		logrus.Printf("SetRolePermissions: cannot archive role permissions:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO role_permissions(role_id, permission_id)
+           SELECT $1, id
+           FROM   permissions
+           WHERE  name = ANY($2)
+           AND    archived_at IS NULL`
+
+	_, err = tx.Exec(SQL, roleID, pq.StringArray(permissions))
+	if err != nil {
+		logrus.Printf("SetRolePermissions: cannot add role permissions:%v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,506 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"strings"
+)
+
+var (
+	ErrIllegalTaskTransition = errors.New("illegal task status transition")
+	ErrInvalidTaskClosure    = errors.New("unknown task outcome or reason code")
+	ErrInvalidAssignee       = errors.New("assignee cannot own this task type")
+	ErrTaskBlocked           = errors.New("task is waiting on prerequisite tasks")
+	ErrPrerequisiteCycle     = errors.New("task type prerequisites cannot form a cycle")
+)
+
+// actions that change the status of a task
+const (
+	taskActionStart    = "start"
+	taskActionComplete = "complete"
+	taskActionClose    = "close"
+	taskActionReopen   = "reopen"
+)
+
+// taskTransitions maps every action to the statuses it can be taken from and the status it leads to.
+// Tasks are only completed after being started, but can be closed as not applicable or rejected right away.
+var taskTransitions = map[string]map[string]string{
+	taskActionStart: {
+		utilities.TaskStatusNew: utilities.TaskStatusProcessing,
+	},
+	taskActionComplete: {
+		utilities.TaskStatusProcessing: utilities.TaskStatusCompleted,
+	},
+	taskActionClose: {
+		utilities.TaskStatusNew:        utilities.TaskStatusCompleted,
+		utilities.TaskStatusProcessing: utilities.TaskStatusCompleted,
+	},
+	taskActionReopen: {
+		utilities.TaskStatusCompleted: utilities.TaskStatusProcessing,
+	},
+}
+
+// transitionTask locks the task, checks the action against taskTransitions and returns the status it leaves and the one it moves to
+func transitionTask(taskID int, action string, tx *sqlx.Tx) (string, string, error) {
+	// language=SQL
+	SQL := `SELECT status,
+                   start_date IS NOT NULL as is_started
+            FROM   task
+            WHERE  id = $1
+            AND    archived_at IS NULL
+            FOR UPDATE`
+
+	var task struct {
+		Status    string `db:"status"`
+		IsStarted bool   `db:"is_started"`
+	}
+
+	err := tx.Get(&task, SQL, taskID)
+	if err != nil {
+		logrus.Printf("transitionTask: cannot get task status:%v", err)
+		return "", "", err
+	}
+
+	to, err := nextTaskStatus(action, task.Status, task.IsStarted)
+	return task.Status, to, err
+}
+
+// nextTaskStatus returns the status the action moves a task in status to, ErrIllegalTaskTransition if it cannot be taken
+func nextTaskStatus(action, status string, isStarted bool) (string, error) {
+	to, ok := taskTransitions[action][status]
+	if !ok {
+		return "", ErrIllegalTaskTransition
+	}
+	// a reopened task that was never started goes back to new
+	if action == taskActionReopen && !isStarted {
+		to = utilities.TaskStatusNew
+	}
+	return to, nil
+}
+
+// checkTaskPrerequisites refuses to go on while a prerequisite task of the same death is not done, see the task_blocked_by view
+func checkTaskPrerequisites(taskID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `SELECT coalesce((SELECT blocked_by
+                            FROM   task_blocked_by
+                            WHERE  task_id = $1), '{}')`
+
+	var blockedBy pq.StringArray
+
+	err := tx.Get(&blockedBy, SQL, taskID)
+	if err != nil {
+		logrus.Printf("checkTaskPrerequisites: cannot get prerequisites:%v", err)
+		return err
+	}
+	if len(blockedBy) > 0 {
+		return fmt.Errorf("%w: %s", ErrTaskBlocked, strings.Join(blockedBy, ", "))
+	}
+	return nil
+}
+
+func AddTaskStatusHistory(taskID int, from, to string, actorID int, closure models.TaskClosure, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `INSERT INTO task_status_history(task_id, from_status, to_status, actor_id, outcome, reason_code, reason)
+            VALUES ($1, $2, $3, $4, nullif($5, '')::task_outcome_type, nullif($6, ''), nullif($7, ''))`
+
+	_, err := tx.Exec(SQL, taskID, from, to, actorID, closure.Outcome, closure.ReasonCode, closure.Reason)
+	if err != nil {
+		logrus.Printf("AddTaskStatusHistory: cannot add task status history:%v", err)
+		return err
+	}
+	return nil
+}
+
+// checkTaskClosure makes sure the outcome is known and the reason code, needed unless the task was simply completed, belongs to it
+func checkTaskClosure(closure models.TaskClosure, tx *sqlx.Tx) error {
+	switch closure.Outcome {
+	case utilities.TaskOutcomeCompleted:
+		if closure.ReasonCode == "" {
+			return nil
+		}
+	case utilities.TaskOutcomeNotApplicable, utilities.TaskOutcomeRejected:
+		if closure.ReasonCode == "" {
+			return ErrInvalidTaskClosure
+		}
+	default:
+		return ErrInvalidTaskClosure
+	}
+
+	// language=SQL
+	SQL := `SELECT EXISTS(SELECT 1
+                          FROM   task_outcome_reason
+                          WHERE  outcome = $1
+                          AND    code = $2
+                          AND    archived_at IS NULL)`
+
+	var isValid bool
+
+	err := tx.Get(&isValid, SQL, closure.Outcome, closure.ReasonCode)
+	if err != nil {
+		logrus.Printf("checkTaskClosure: cannot check reason code:%v", err)
+		return err
+	}
+	if !isValid {
+		return ErrInvalidTaskClosure
+	}
+	return nil
+}
+
+func GetTaskOutcomeReasons() ([]models.TaskOutcomeReason, error) {
+	// language=SQL
+	SQL := `SELECT id,
+                   outcome,
+                   code,
+                   description
+            FROM   task_outcome_reason
+            WHERE  archived_at IS NULL
+            ORDER BY outcome, code`
+
+	reasons := make([]models.TaskOutcomeReason, 0)
+
+	err := database.GramPanchayatDB.Select(&reasons, SQL)
+	if err != nil {
+		logrus.Printf("GetTaskOutcomeReasons: cannot get reasons:%v", err)
+		return reasons, err
+	}
+	return reasons, nil
+}
+
+func GetTaskStatusHistory(taskID int) ([]models.TaskStatusHistory, error) {
+	// language=SQL
+	SQL := `SELECT tsh.id,
+                   tsh.task_id,
+                   tsh.from_status,
+                   tsh.to_status,
+                   tsh.outcome,
+                   tsh.reason_code,
+                   tsh.actor_id,
+                   u.name as actor_name,
+                   tsh.reason,
+                   tsh.created_at
+            FROM   task_status_history tsh
+                   LEFT JOIN users u on u.id = tsh.actor_id
+            WHERE  tsh.task_id = $1
+            ORDER BY tsh.created_at, tsh.id`
+
+	history := make([]models.TaskStatusHistory, 0)
+
+	err := database.GramPanchayatDB.Select(&history, SQL, taskID)
+	if err != nil {
+		logrus.Printf("GetTaskStatusHistory: cannot get task status history:%v", err)
+		return history, err
+	}
+	return history, nil
+}
+
+func GetTaskDeathID(taskID int) (int, error) {
+	// language=SQL
+	SQL := `SELECT death_id
+            FROM   task
+            WHERE  id = $1
+            AND    archived_at IS NULL`
+
+	var deathID int
+
+	err := database.GramPanchayatDB.Get(&deathID, SQL, taskID)
+	if err != nil {
+		logrus.Printf("GetTaskDeathID: cannot get death of task:%v", err)
+		return deathID, err
+	}
+	return deathID, nil
+}
+
+// AssignDeathTasks gives every task of the death to the district post holder owning the task type,
+// or to the SDM of the death's tehsil when the task type has no district post
+func AssignDeathTasks(deathID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE task t
+            SET    user_id = coalesce(
+                           (SELECT u.id
+                            FROM   users u
+                                   JOIN roles r on r.id = u.roles_id
+                                   JOIN task_role tr on tr.role_id = r.id
+                            WHERE  tr.task_type_id = t.task_type_id
+                            AND    r.is_district_level
+                            AND    u.archived_at IS NULL
+                            ORDER BY u.id
+                            LIMIT 1),
+                           (SELECT u.id
+                            FROM   users u
+                                   JOIN roles r on r.id = u.roles_id
+                                   JOIN user_tehsil ut on ut.user_id = u.id AND ut.archived_at IS NULL
+                            WHERE  r.role = $2
+                            AND    ut.tehsil_id = gp.tehsil_id
+                            AND    u.archived_at IS NULL
+                            ORDER BY u.id
+                            LIMIT 1)),
+                   assigned_at = now()
+            FROM   death_details dd
+                   JOIN gram_panchayat gp on gp.id = dd.gram_panchayat_id
+            WHERE  t.death_id = dd.id
+            AND    dd.id = $1
+            AND    t.archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, deathID, utilities.SDM)
+	if err != nil {
+		logrus.Printf("AssignDeathTasks: cannot assign tasks:%v", err)
+		return err
+	}
+	return nil
+}
+
+// AssignTask hands the task to another official, whose role has to own the task type
+func AssignTask(taskID, userID int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `SELECT EXISTS(SELECT 1
+                          FROM   task t
+                                 JOIN task_role tr on tr.task_type_id = t.task_type_id
+                                 JOIN users u on u.roles_id = tr.role_id
+                          WHERE  t.id = $1
+                          AND    u.id = $2
+                          AND    u.archived_at IS NULL)`
+
+	var canOwn bool
+
+	err := tx.Get(&canOwn, SQL, taskID, userID)
+	if err != nil {
+		logrus.Printf("AssignTask: cannot check assignee:%v", err)
+		return err
+	}
+	if !canOwn {
+		return ErrInvalidAssignee
+	}
+
+	// language=SQL
+	SQL = `UPDATE task
+           SET    user_id = $1,
+                  assigned_at = now()
+           WHERE  id = $2
+           AND    archived_at IS NULL`
+
+	_, err = tx.Exec(SQL, userID, taskID)
+	if err != nil {
+		logrus.Printf("AssignTask: cannot assign task:%v", err)
+		return err
+	}
+	return nil
+}
+
+// GetMyTasks lists the tasks assigned to the user, optionally only the ones in the given status
+func GetMyTasks(userID int, status string) ([]models.AssignedTask, error) {
+	// language=SQL
+	SQL := `SELECT t.id,
+                   tt.name as task_type,
+                   t.status,
+                   t.outcome,
+                   t.start_date,
+                   t.assigned_at,
+                   dd.id as death_id,
+                   dd.name,
+                   dd.date_of_death,
+                   gp.name as gram_panchayat_name,
+                   g.name as gaon_name
+            FROM   task t
+                   JOIN task_types tt on tt.id = t.task_type_id
+                   JOIN death_details dd on dd.id = t.death_id
+                   JOIN gram_panchayat gp on gp.id = dd.gram_panchayat_id
+                   JOIN gaon g on g.id = dd.gaon_id
+            WHERE  t.user_id = $1
+            AND    t.archived_at IS NULL
+            AND    dd.archived_at IS NULL
+            AND    ($2 = '' OR t.status::TEXT = $2)
+            ORDER BY t.assigned_at, t.id`
+
+	tasks := make([]models.AssignedTask, 0)
+
+	err := database.GramPanchayatDB.Select(&tasks, SQL, userID, status)
+	if err != nil {
+		logrus.Printf("GetMyTasks: cannot get assigned tasks:%v", err)
+		return tasks, err
+	}
+	return tasks, nil
+}
+
+// escalationRecipients find the official an overdue task is escalated to at each level
+var escalationRecipients = map[int]string{
+	utilities.EscalationSachiv: `SELECT u.id
+                                 FROM   task t
+                                        JOIN death_details dd on dd.id = t.death_id
+                                        JOIN user_gram_panchayat ugp on ugp.gram_panchayat_id = dd.gram_panchayat_id
+                                        JOIN users u on u.id = ugp.user_id
+                                        JOIN roles r on r.id = u.roles_id
+                                 WHERE  t.id = $1
+                                 AND    r.role = '` + utilities.Sachiv + `'
+                                 AND    u.archived_at IS NULL
+                                 ORDER BY u.id
+                                 LIMIT 1`,
+	utilities.EscalationSDM: `SELECT u.id
+                              FROM   task t
+                                     JOIN death_details dd on dd.id = t.death_id
+                                     JOIN gram_panchayat gp on gp.id = dd.gram_panchayat_id
+                                     JOIN user_tehsil ut on ut.tehsil_id = gp.tehsil_id AND ut.archived_at IS NULL
+                                     JOIN users u on u.id = ut.user_id
+                                     JOIN roles r on r.id = u.roles_id
+                              WHERE  t.id = $1
+                              AND    r.role = '` + utilities.SDM + `'
+                              AND    u.archived_at IS NULL
+                              ORDER BY u.id
+                              LIMIT 1`,
+	utilities.EscalationDistrict: `SELECT u.id
+                                   FROM   task t
+                                          JOIN task_role tr on tr.task_type_id = t.task_type_id
+                                          JOIN roles r on r.id = tr.role_id
+                                          JOIN users u on u.roles_id = r.id
+                                   WHERE  t.id = $1
+                                   AND    r.is_district_level
+                                   AND    u.archived_at IS NULL
+                                   ORDER BY u.id
+                                   LIMIT 1`,
+}
+
+// GetOpenTasksWithSLA returns the tasks that are not completed and whose type has an sla
+func GetOpenTasksWithSLA() ([]models.OverdueTask, error) {
+	// language=SQL
+	SQL := `SELECT t.id,
+                   tt.name as task_type,
+                   dd.name as death_name,
+                   sss.sla_started_at,
+                   tt.sla_days,
+                   tt.sla_working_days,
+                   t.escalation_level,
+                   t.escalated_at,
+                   t.user_id
+            FROM   task t
+                   JOIN task_types tt on tt.id = t.task_type_id
+                   JOIN death_details dd on dd.id = t.death_id
+                   JOIN task_sla_start sss on sss.task_id = t.id
+            WHERE  t.status != $1
+            AND    tt.sla_days IS NOT NULL
+            AND    sss.sla_started_at IS NOT NULL
+            AND    t.archived_at IS NULL
+            AND    dd.archived_at IS NULL`
+
+	tasks := make([]models.OverdueTask, 0)
+
+	err := database.GramPanchayatDB.Select(&tasks, SQL, utilities.TaskStatusCompleted)
+	if err != nil {
+		logrus.Printf("GetOpenTasksWithSLA: cannot get tasks:%v", err)
+		return tasks, err
+	}
+	return tasks, nil
+}
+
+// GetEscalationRecipient returns sql.ErrNoRows when nobody holds the post for the task
+func GetEscalationRecipient(taskID, level int) (int, error) {
+	var userID int
+
+	err := database.GramPanchayatDB.Get(&userID, escalationRecipients[level], taskID)
+	return userID, err
+}
+
+func EscalateTask(taskID, level int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE task
+            SET    escalation_level = $1,
+                   escalated_at = now()
+            WHERE  id = $2
+            AND    escalation_level < $1`
+
+	_, err := tx.Exec(SQL, level, taskID)
+	if err != nil {
+		logrus.Printf("EscalateTask: cannot escalate task:%v", err)
+		return err
+	}
+	return nil
+}
+
+func SetTaskTypeSLA(taskTypeID int, sla models.TaskTypeSLA, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE task_types
+            SET    sla_days = nullif($1, 0),
+                   sla_working_days = $2
+            WHERE  id = $3`
+
+	_, err := tx.Exec(SQL, sla.SLADays, sla.WorkingDays, taskTypeID)
+	if err != nil {
+		logrus.Printf("SetTaskTypeSLA: cannot set sla:%v", err)
+		return err
+	}
+	return nil
+}
+
+func SetTaskTypeMonetary(taskTypeID int, isMonetary bool, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE task_types
+            SET    is_monetary = $1
+            WHERE  id = $2`
+
+	_, err := tx.Exec(SQL, isMonetary, taskTypeID)
+	if err != nil {
+		logrus.Printf("SetTaskTypeMonetary: cannot set monetary:%v", err)
+		return err
+	}
+	return nil
+}
+
+// SetTaskTypePrerequisites replaces the prerequisites of the task type, a set that makes the task type wait on itself is refused
+func SetTaskTypePrerequisites(taskTypeID int, prerequisiteIDs []int, tx *sqlx.Tx) error {
+	// language=SQL
+	SQL := `UPDATE task_type_prerequisite
+            SET    archived_at = now()
+            WHERE  task_type_id = $1
+            AND    archived_at IS NULL`
+
+	_, err := tx.Exec(SQL, taskTypeID)
+	if err != nil {
+		logrus.Printf("SetTaskTypePrerequisites: cannot archive prerequisites:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO task_type_prerequisite(task_type_id, prerequisite_task_type_id)
+           SELECT $1, id
+           FROM   task_types
+           WHERE  id = ANY($2)
+           AND    id != $1`
+
+	_, err = tx.Exec(SQL, taskTypeID, pq.Array(prerequisiteIDs))
+	if err != nil {
+		logrus.Printf("SetTaskTypePrerequisites: cannot add prerequisites:%v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL = `WITH RECURSIVE prerequisites(task_type_id) AS (
+                SELECT prerequisite_task_type_id
+                FROM   task_type_prerequisite
+                WHERE  task_type_id = $1
+                AND    archived_at IS NULL
+                UNION
+                SELECT ttp.prerequisite_task_type_id
+                FROM   task_type_prerequisite ttp
+                       JOIN prerequisites p on p.task_type_id = ttp.task_type_id
+                WHERE  ttp.archived_at IS NULL
+           )
+           SELECT EXISTS(SELECT 1
+                         FROM   prerequisites
+                         WHERE  task_type_id = $1)`
+
+	var isCycle bool
+
+	err = tx.Get(&isCycle, SQL, taskTypeID)
+	if err != nil {
+		logrus.Printf("SetTaskTypePrerequisites: cannot check for cycles:%v", err)
+		return err
+	}
+	if isCycle {
+		return ErrPrerequisiteCycle
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package helper
+
+import (
+	"grampanchayat/utilities"
+	"testing"
+)
+
+func TestNextTaskStatus(t *testing.T) {
+	tests := []struct {
+		action    string
+		status    string
+		isStarted bool
+		want      string
+		wantErr   bool
+	}{
+		{taskActionStart, utilities.TaskStatusNew, false, utilities.TaskStatusProcessing, false},
+		{taskActionStart, utilities.TaskStatusProcessing, true, "", true},
+		{taskActionStart, utilities.TaskStatusCompleted, true, "", true},
+		{taskActionComplete, utilities.TaskStatusProcessing, true, utilities.TaskStatusCompleted, false},
+		{taskActionComplete, utilities.TaskStatusNew, false, "", true},
+		{taskActionComplete, utilities.TaskStatusCompleted, true, "", true},
+		{taskActionClose, utilities.TaskStatusNew, false, utilities.TaskStatusCompleted, false},
+		{taskActionClose, utilities.TaskStatusProcessing, true, utilities.TaskStatusCompleted, false},
+		{taskActionClose, utilities.TaskStatusCompleted, true, "", true},
+		{taskActionReopen, utilities.TaskStatusCompleted, true, utilities.TaskStatusProcessing, false},
+		{taskActionReopen, utilities.TaskStatusCompleted, false, utilities.TaskStatusNew, false},
+		{taskActionReopen, utilities.TaskStatusNew, false, "", true},
+		{taskActionReopen, utilities.TaskStatusProcessing, true, "", true},
+		{"archive", utilities.TaskStatusNew, false, "", true},
+		{taskActionStart, "unknown", false, "", true},
+	}
+
+	for _, test := range tests {
+		got, err := nextTaskStatus(test.action, test.status, test.isStarted)
+		if test.wantErr {
+			if err != ErrIllegalTaskTransition {
+				t.Errorf("nextTaskStatus(%q, %q, %v) error = %v, want %v", test.action, test.status, test.isStarted, err, ErrIllegalTaskTransition)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("nextTaskStatus(%q, %q, %v) = %q, %v, want %q", test.action, test.status, test.isStarted, got, err, test.want)
+		}
+	}
+}
+
+// every status reached by an action has to be one the task can be in
+func TestTaskTransitionsStayInStatuses(t *testing.T) {
+	statuses := map[string]bool{
+		utilities.TaskStatusNew:        true,
+		utilities.TaskStatusProcessing: true,
+		utilities.TaskStatusCompleted:  true,
+	}
+	for action, transitions := range taskTransitions {
+		for from, to := range transitions {
+			if !statuses[from] || !statuses[to] {
+				t.Errorf("%s moves %q to %q, not a task status", action, from, to)
+			}
+		}
+	}
+}
@@ -7,30 +7,56 @@ import (
 	"github.com/sirupsen/logrus"
 	"grampanchayat/database"
 	"grampanchayat/models"
+	"grampanchayat/utilities"
 )
 
 func DeathRegistration(deathDetails models.DeathRegistrationRequest, createdBy int, tx *sqlx.Tx) (int, error) {
-	SQL := `INSERT INTO death_details(name, phone_no, age, gender, aadhar_number, status, created_by, gram_panchayat_id, date_of_death, gaon_id)
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	SQL := `INSERT INTO death_details(name, phone_no, age, gender, aadhar_number, status, created_by, gram_panchayat_id, date_of_death, gaon_id,
+                                      cause_of_death, place_of_death, occupation, is_bpl, schemes, circumstance)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, nullif($11, ''), nullif($12, ''), nullif($13, ''), $14, coalesce($15::text[], '{}'), nullif($16, ''))
             RETURNING id`
 
 	var deathID int
 
-	err := tx.Get(&deathID, SQL, deathDetails.Name, deathDetails.PhoneNo, deathDetails.Age, deathDetails.Gender, deathDetails.AadharNumber, "new", createdBy, deathDetails.PanchayatID, deathDetails.DateOfDeath, deathDetails.GaonID)
+	err := tx.Get(&deathID, SQL, deathDetails.Name, deathDetails.PhoneNo, deathDetails.Age, deathDetails.Gender, deathDetails.AadharNumber, "new", createdBy, deathDetails.PanchayatID, deathDetails.DateOfDeath, deathDetails.GaonID,
+		deathDetails.CauseOfDeath, deathDetails.PlaceOfDeath, deathDetails.Occupation, deathDetails.IsBPL, pq.StringArray(deathDetails.Schemes), deathDetails.Circumstance)
 	if err != nil {
 		logrus.Printf("DeathRegistration: cannot register death:%v", err)
 		return deathID, err
 	}
 
+	// only the task types whose eligibility rules the death meets get a task
+	taskTypeIDs, err := decideDeathTasks(deathID, deathDetails, tx)
+	if err != nil {
+		return deathID, err
+	}
+
 	SQL = `insert into task (death_id, task_type_id, status)
-			select $1, id, 'new' from task_types`
+			select $1, id, 'new' from task_types where id = any($2)`
 
-	_, err = tx.Exec(SQL, deathID)
+	_, err = tx.Exec(SQL, deathID, pq.Array(taskTypeIDs))
 	if err != nil {
 		logrus.Printf("DeathRegistration: cannot register tasks:%v", err)
 		return deathID, err
 	}
 
+	// language=SQL
+	SQL = `INSERT INTO task_status_history(task_id, to_status, actor_id)
+           SELECT id, status, $2
+           FROM   task
+           WHERE  death_id = $1`
+
+	_, err = tx.Exec(SQL, deathID, createdBy)
+	if err != nil {
+		logrus.Printf("DeathRegistration: cannot add task status history:%v", err)
+		return deathID, err
+	}
+
+	err = AssignDeathTasks(deathID, tx)
+	if err != nil {
+		return deathID, err
+	}
+
 	return deathID, nil
 }
 
@@ -75,6 +101,9 @@ select id,
        date_of_death,
        gaon_id,
        gaon_name,
+       cause_of_death,
+       place_of_death,
+       circumstance,
        task_details
 from (SELECT death_details.id,
              death_details.name,
@@ -88,21 +117,36 @@ from (SELECT death_details.id,
              death_details.date_of_death,
              gaon.id as gaon_id,
              gaon.name as gaon_name,
+             coalesce(death_details.cause_of_death, '') as cause_of_death,
+             coalesce(death_details.place_of_death, '') as place_of_death,
+             coalesce(death_details.circumstance, '') as circumstance,
              count(t.status) filter (where t.status = 'completed')  as completed_tasks,
              count(t.status) filter (where t.status = 'new')        as new_tasks,
              count(t.status) filter (where t.status = 'processing') as processing_tasks,
-             count(*)                                               as all_tasks,
-             json_agg(json_build_object('taskId', t.id::text, 'status', t.status::text, 'name',
+             count(t.id)                                            as all_tasks,
+             coalesce(json_agg(json_build_object('taskId', t.id::text, 'status', t.status::text, 'name',
                                         task_types.name, 'startDate',
                                         t.start_date::DATE, 'completeDate',
                                         t.completed_date::DATE,
                  						'isRejected',t.is_rejected,
-                 						'reason',t.reason))    as task_details
+                 						'outcome',t.outcome,
+                 						'reasonCode',t.reason_code,
+                 						'assignedTo',t.user_id,
+                 						'createdAt',t.created_at,
+                 						'slaStartedAt',sss.sla_started_at,
+                 						'slaDays',task_types.sla_days,
+                 						'slaWorkingDays',task_types.sla_working_days,
+                 						'escalationLevel',t.escalation_level,
+                 						'blockedBy',coalesce(tbb.blocked_by, '{}'),
+                 						'reason',t.reason)) filter (where t.id is not null), '[]')    as task_details
       FROM death_details
                JOIN death_details_address dda on death_details.id = dda.death_detail_id
                JOIN address a on a.id = dda.address_id
-               JOIN task t on death_details.id = t.death_id
-               JOIN task_types on task_types.id = t.task_type_id
+               LEFT JOIN task t on death_details.id = t.death_id
+                    and t.task_type_id in (select id from task_types where name = any ($1))
+               LEFT JOIN task_types on task_types.id = t.task_type_id
+               LEFT JOIN task_blocked_by tbb on tbb.task_id = t.id
+               LEFT JOIN task_sla_start sss on sss.task_id = t.id
                JOIN users on users.id = $2
                JOIN roles r on users.roles_id = r.id
                LEFT JOIN user_gram_panchayat
@@ -113,9 +157,11 @@ from (SELECT death_details.id,
                LEFT JOIN user_tehsil on gp.tehsil_id = user_tehsil.tehsil_id and
                                         users.id = user_tehsil.user_id
       		   LEFT JOIN user_gaon ug on gaon.id = ug.gaon_id and users.id = ug.user_id
+               LEFT JOIN user_block ub on gp.block_id = ub.block_id and users.id = ub.user_id and ub.archived_at IS NULL
       WHERE death_details.archived_at IS NULL
-        and task_types.name = any ($1)
-        and (r.is_district_level OR user_gram_panchayat.id is not null OR user_tehsil.id is not null OR ug.id is not null)
+        -- a death none of the tasks were created for still shows, in the no tasks list
+        and (t.id is not null or not exists(select 1 from task where task.death_id = death_details.id))
+        and (r.is_district_level OR user_gram_panchayat.id is not null OR user_tehsil.id is not null OR ug.id is not null OR ub.id is not null)
       group by (death_details.id,
                 death_details.name,
                 death_details.phone_no,
@@ -135,9 +181,11 @@ from (SELECT death_details.id,
 	if status == "processing" {
 		statusWhereClause = "(completed_tasks != all_tasks AND new_tasks = 0) "
 	} else if status == "completed" {
-		statusWhereClause = "completed_tasks = all_tasks "
+		statusWhereClause = "completed_tasks = all_tasks AND all_tasks > 0 "
 	} else if status == "new" {
 		statusWhereClause = "new_tasks > 0 "
+	} else if status == utilities.DeathStatusNoTasks {
+		statusWhereClause = "all_tasks = 0 "
 	}
 
 	SQL = fmt.Sprintf(SQL, statusWhereClause)
@@ -160,49 +208,120 @@ from (SELECT death_details.id,
 	return deathDetails, nil
 }
 
-func MarkProcessing(taskID int) error {
+func MarkProcessing(taskID, actorID int, tx *sqlx.Tx) error {
+	from, to, err := transitionTask(taskID, taskActionStart, tx)
+	if err != nil {
+		return err
+	}
+
+	err = checkTaskPrerequisites(taskID, tx)
+	if err != nil {
+		return err
+	}
+
 	SQL := `UPDATE task 
             SET    status = $1,
                    start_date = now()
             WHERE  id = $2
             AND    archived_at IS NULL `
 
-	_, err := database.GramPanchayatDB.Exec(SQL, "processing", taskID)
+	_, err = tx.Exec(SQL, to, taskID)
 	if err != nil {
 		logrus.Printf("MarkProcessing: cannot update status to processing:%v", err)
 		return err
 	}
-	return nil
+	return AddTaskStatusHistory(taskID, from, to, actorID, models.TaskClosure{}, tx)
 }
 
-func MarkCompleted(taskID int) error {
+// CloseTask completes the task with the given outcome. Only started tasks can be completed,
+// a task that does not apply or was rejected can be closed without starting it.
+func CloseTask(taskID, actorID int, closure models.TaskClosure, tx *sqlx.Tx) error {
+	err := checkTaskClosure(closure, tx)
+	if err != nil {
+		return err
+	}
+
+	action := taskActionClose
+	if closure.Outcome == utilities.TaskOutcomeCompleted {
+		action = taskActionComplete
+	}
+	from, to, err := transitionTask(taskID, action, tx)
+	if err != nil {
+		return err
+	}
+
 	SQL := `UPDATE task 
             SET    status = $1,
+                   outcome = $2,
+                   reason_code = nullif($3, ''),
+                   reason = nullif($4, ''),
+                   is_rejected = $2 = 'rejected',
                    completed_date = now()
-            WHERE  id = $2
+            WHERE  id = $5
             AND    archived_at IS NULL `
 
-	_, err := database.GramPanchayatDB.Exec(SQL, "completed", taskID)
+	_, err = tx.Exec(SQL, to, closure.Outcome, closure.ReasonCode, closure.Reason, taskID)
 	if err != nil {
-		logrus.Printf("MarkCompleted: cannot update status to completed:%v", err)
+		logrus.Printf("CloseTask: cannot update status to completed:%v", err)
 		return err
 	}
-	return nil
+	return AddTaskStatusHistory(taskID, from, to, actorID, closure, tx)
 }
 
-func TaskRejected(taskID int, processing models.Processing) error {
+// ReopenTask undoes the completion of a task, it goes back to processing or to new if it was never started.
+// Its sla starts over, so does its escalation.
+func ReopenTask(taskID, actorID int, reason string, tx *sqlx.Tx) error {
+	from, to, err := transitionTask(taskID, taskActionReopen, tx)
+	if err != nil {
+		return err
+	}
+
 	SQL := `UPDATE task 
             SET    status = $1,
-                   is_rejected = true,
-                   reason = $2,
-                   completed_date = now()
-            WHERE  id = $3
+                   outcome = NULL,
+                   reason_code = NULL,
+                   reason = NULL,
+                   is_rejected = false,
+                   completed_date = NULL,
+                   escalation_level = 0,
+                   escalated_at = NULL
+            WHERE  id = $2
             AND    archived_at IS NULL `
 
-	_, err := database.GramPanchayatDB.Exec(SQL, "completed", processing.Reason, taskID)
+	_, err = tx.Exec(SQL, to, taskID)
 	if err != nil {
-		logrus.Printf("TaskRejected: cannot update status to completed:%v", err)
+		logrus.Printf("ReopenTask: cannot reopen task:%v", err)
 		return err
 	}
-	return nil
+	return AddTaskStatusHistory(taskID, from, to, actorID, models.TaskClosure{Reason: reason}, tx)
+}
+
+// GetTaskAccess checks the task against the caller's task types and postings, sql.ErrNoRows if the task does not exist
+func GetTaskAccess(taskID, userID int, actionableTaskTypes []string) (models.TaskAccess, error) {
+	// language=SQL
+	SQL := `SELECT task_types.name = ANY($3) as is_actionable,
+                   (r.is_district_level OR ugp.id IS NOT NULL OR ut.id IS NOT NULL OR ug.id IS NOT NULL OR ub.id IS NOT NULL) as in_jurisdiction
+            FROM   task t
+                   JOIN task_types on task_types.id = t.task_type_id
+                   JOIN death_details dd on t.death_id = dd.id
+                   JOIN gram_panchayat gp on dd.gram_panchayat_id = gp.id
+                   JOIN users on users.id = $2
+                   JOIN roles r on users.roles_id = r.id
+                   LEFT JOIN user_gram_panchayat ugp on dd.gram_panchayat_id = ugp.gram_panchayat_id and users.id = ugp.user_id
+                   LEFT JOIN user_tehsil ut on gp.tehsil_id = ut.tehsil_id and users.id = ut.user_id
+                   LEFT JOIN user_gaon ug on dd.gaon_id = ug.gaon_id and users.id = ug.user_id
+                   LEFT JOIN user_block ub on gp.block_id = ub.block_id and users.id = ub.user_id and ub.archived_at IS NULL
+            WHERE  t.id = $1
+            AND    t.archived_at IS NULL
+            AND    dd.archived_at IS NULL
+            LIMIT 1`
+
+	var access models.TaskAccess
+
+	err := database.GramPanchayatDB.Get(&access, SQL, taskID, userID, pq.StringArray(actionableTaskTypes))
+	if err != nil {
+		logrus.Printf("GetTaskAccess: cannot check task access:%v", err)
+		return access, err
+	}
+	return access, nil
 }
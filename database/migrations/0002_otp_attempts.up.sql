@@ -0,0 +1,6 @@
+ALTER TABLE otp
+    ADD COLUMN IF NOT EXISTS failed_attempts INTEGER DEFAULT 0 NOT NULL;
+
+-- otps are digit strings, as an integer a leading zero was lost and "0123" never matched
+ALTER TABLE otp
+    ALTER COLUMN otp TYPE TEXT USING lpad(otp::TEXT, 4, '0');
@@ -0,0 +1,2 @@
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
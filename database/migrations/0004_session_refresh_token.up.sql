@@ -0,0 +1,6 @@
+-- previous_refresh_token_hash is the token the session was rotated away from, only presenting it again is taken as a replay
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS refresh_token_hash TEXT,
+    ADD COLUMN IF NOT EXISTS previous_refresh_token_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions(user_id) WHERE archived_at IS NULL;
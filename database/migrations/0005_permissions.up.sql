@@ -0,0 +1,66 @@
+CREATE TABLE IF NOT EXISTS permissions(
+                                          id SERIAL PRIMARY KEY ,
+                                          name TEXT UNIQUE NOT NULL ,
+                                          description TEXT ,
+                                          created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                          updated_at TIMESTAMP WITH TIME ZONE ,
+                                          archived_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions(
+                                               id SERIAL PRIMARY KEY ,
+                                               role_id INTEGER REFERENCES roles(id) NOT NULL ,
+                                               permission_id INTEGER REFERENCES permissions(id) NOT NULL ,
+                                               created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                               updated_at TIMESTAMP WITH TIME ZONE ,
+                                               archived_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS role_permissions_active_idx ON role_permissions(role_id, permission_id) WHERE archived_at IS NULL;
+
+INSERT INTO permissions(name, description)
+VALUES ('death:register', 'register a new death'),
+       ('death:view', 'list deaths and their tasks'),
+       ('death:review', 'review randomly picked completed deaths'),
+       ('task:update', 'start, complete or reject tasks of the role'),
+       ('task:view-all', 'see every task of a death, not only the ones of the role'),
+       ('task-type:view', 'list task types and the roles owning them'),
+       ('dashboard:view', 'view admin dashboards, graphs and death listings'),
+       ('location:view', 'list tehsils, blocks, gram panchayats and gaons'),
+       ('location:manage', 'create and edit tehsils, blocks, gram panchayats and gaons'),
+       ('role:manage', 'create roles'),
+       ('permission:manage', 'grant and revoke permissions of roles'),
+       ('session:revoke', 'log an official out of every device')
+ON CONFLICT (name) DO NOTHING;
+
+-- admins keep everything they could do behind the old role check
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name NOT IN ('death:register', 'task:update');
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role IN ('Sachiv', 'Sahayak')
+  AND p.name IN ('death:register', 'death:view', 'task:view-all');
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Lekhpal'
+  AND p.name IN ('death:view', 'task:view-all');
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'SDM'
+  AND p.name IN ('death:view');
+
+-- department roles act on the task types they own
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT DISTINCT tr.role_id, p.id
+FROM task_role tr, permissions p
+WHERE p.name IN ('death:view', 'task:update')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS user_block(
+                                         id serial primary key,
+                                         block_id INTEGER references block(id) not null ,
+                                         user_id INTEGER references users(id) not null,
+                                         created_at timestamp with time zone default now(),
+                                         updated_at timestamp with time zone,
+                                         archived_at timestamp with time zone
+);
+
+INSERT INTO roles(role)
+SELECT 'BDO'
+WHERE NOT EXISTS(SELECT 1 FROM roles WHERE role = 'BDO');
+
+INSERT INTO permissions(name, description)
+VALUES ('scope:district', 'see admin data of the whole district instead of the posted tehsil or block')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name = 'scope:district'
+ON CONFLICT DO NOTHING;
+
+-- SDMs and block officers get the admin dashboards limited to their tehsil or block
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role IN ('SDM', 'BDO')
+  AND p.name IN ('death:view', 'dashboard:view', 'death:review')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,30 @@
+-- a secret from totp setup waits in totp_pending_secret until a code from it is confirmed, the one in use stays as it is
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS password_hash TEXT,
+    ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS failed_password_attempts INTEGER DEFAULT 0 NOT NULL,
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS totp_secret TEXT,
+    ADD COLUMN IF NOT EXISTS totp_pending_secret TEXT,
+    ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN DEFAULT false NOT NULL;
+
+CREATE TABLE IF NOT EXISTS password_reset(
+                                             id SERIAL PRIMARY KEY ,
+                                             user_id INTEGER REFERENCES users(id) NOT NULL ,
+                                             token_hash TEXT UNIQUE NOT NULL ,
+                                             expires_at TIMESTAMP WITH TIME ZONE NOT NULL ,
+                                             used_at TIMESTAMP WITH TIME ZONE ,
+                                             created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+
+INSERT INTO permissions(name, description)
+VALUES ('auth:password-login', 'log in with a password instead of an sms otp'),
+       ('auth:password-reset', 'issue password reset tokens for other officials')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name IN ('auth:password-login', 'auth:password-reset')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS audit_log(
+                                        id BIGSERIAL PRIMARY KEY ,
+                                        actor_id INTEGER REFERENCES users(id) NOT NULL ,
+                                        actor_role TEXT NOT NULL ,
+                                        action TEXT NOT NULL ,
+                                        entity TEXT NOT NULL ,
+                                        entity_id INTEGER NOT NULL ,
+                                        before JSON DEFAULT 'null' NOT NULL ,
+                                        after JSON DEFAULT 'null' NOT NULL ,
+                                        created_at TIMESTAMP WITH TIME ZONE NOT NULL ,
+                                        prev_hash TEXT NOT NULL ,
+                                        hash TEXT UNIQUE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log(entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log(actor_id);
+
+-- entries are only ever appended, the hash chain shows if someone works around this
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE PROCEDURE audit_log_append_only();
+
+INSERT INTO permissions(name, description)
+VALUES ('audit:view', 'see the audit log of every change and verify its hash chain')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name = 'audit:view'
+ON CONFLICT DO NOTHING;
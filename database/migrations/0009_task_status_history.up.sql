@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS task_status_history(
+                                                  id SERIAL PRIMARY KEY ,
+                                                  task_id INTEGER REFERENCES task(id) NOT NULL ,
+                                                  from_status status_type ,
+                                                  to_status status_type NOT NULL ,
+                                                  actor_id INTEGER REFERENCES users(id) ,
+                                                  reason TEXT ,
+                                                  created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS task_status_history_task_idx ON task_status_history(task_id);
+
+-- history of the tasks that were already there, as far as their dates tell it
+INSERT INTO task_status_history(task_id, from_status, to_status, created_at)
+SELECT id, NULL, 'new', created_at
+FROM task;
+
+INSERT INTO task_status_history(task_id, from_status, to_status, created_at)
+SELECT id, 'new', 'processing', start_date
+FROM task
+WHERE start_date IS NOT NULL;
+
+INSERT INTO task_status_history(task_id, from_status, to_status, reason, created_at)
+SELECT id, CASE WHEN start_date IS NULL THEN 'new' ELSE 'processing' END::status_type, 'completed', reason, completed_date
+FROM task
+WHERE completed_date IS NOT NULL
+  AND status = 'completed';
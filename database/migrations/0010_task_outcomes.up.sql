@@ -0,0 +1,43 @@
+create type task_outcome_type as enum('completed', 'not_applicable', 'rejected');
+
+CREATE TABLE IF NOT EXISTS task_outcome_reason(
+                                                  id SERIAL PRIMARY KEY ,
+                                                  outcome task_outcome_type NOT NULL ,
+                                                  code TEXT UNIQUE NOT NULL ,
+                                                  description TEXT NOT NULL ,
+                                                  created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                                  archived_at TIMESTAMP WITH TIME ZONE
+);
+
+INSERT INTO task_outcome_reason(outcome, code, description)
+VALUES ('completed', 'benefit-delivered', 'benefit or document handed over to the family'),
+       ('completed', 'handed-to-department', 'completed by another department'),
+       ('not_applicable', 'no-policy', 'deceased had no insurance policy'),
+       ('not_applicable', 'not-eligible', 'family is not eligible for the scheme'),
+       ('not_applicable', 'no-dependants', 'no dependants left to claim'),
+       ('not_applicable', 'already-done', 'already done before the death was registered'),
+       ('rejected', 'claim-denied', 'claim denied by the department'),
+       ('rejected', 'documents-missing', 'required documents were not submitted'),
+       ('rejected', 'verification-failed', 'details could not be verified')
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE task
+    ADD COLUMN IF NOT EXISTS outcome task_outcome_type,
+    ADD COLUMN IF NOT EXISTS reason_code TEXT REFERENCES task_outcome_reason(code);
+
+-- closed tasks so far were either completed or rejected, not applicable did not exist yet
+UPDATE task
+SET outcome = CASE WHEN coalesce(is_rejected, false) THEN 'rejected' ELSE 'completed' END::task_outcome_type
+WHERE status = 'completed'
+  AND outcome IS NULL;
+
+ALTER TABLE task_status_history
+    ADD COLUMN IF NOT EXISTS outcome task_outcome_type,
+    ADD COLUMN IF NOT EXISTS reason_code TEXT;
+
+UPDATE task_status_history tsh
+SET outcome = t.outcome
+FROM task t
+WHERE t.id = tsh.task_id
+  AND tsh.to_status = 'completed'
+  AND tsh.outcome IS NULL;
@@ -0,0 +1,10 @@
+INSERT INTO permissions(name, description)
+VALUES ('task:reopen', 'reopen a completed task of any department')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name = 'task:reopen'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,44 @@
+ALTER TABLE task
+    ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS task_user_idx ON task(user_id) WHERE archived_at IS NULL;
+
+-- open tasks get the same official a newly registered death would get
+UPDATE task t
+SET user_id = coalesce(
+        (SELECT u.id
+         FROM users u
+                  JOIN roles r on r.id = u.roles_id
+                  JOIN task_role tr on tr.role_id = r.id
+         WHERE tr.task_type_id = t.task_type_id
+           AND r.is_district_level
+           AND u.archived_at IS NULL
+         ORDER BY u.id
+         LIMIT 1),
+        (SELECT u.id
+         FROM users u
+                  JOIN roles r on r.id = u.roles_id
+                  JOIN user_tehsil ut on ut.user_id = u.id AND ut.archived_at IS NULL
+         WHERE r.role = 'SDM'
+           AND ut.tehsil_id = gp.tehsil_id
+           AND u.archived_at IS NULL
+         ORDER BY u.id
+         LIMIT 1)),
+    assigned_at = now()
+FROM death_details dd
+         JOIN gram_panchayat gp on gp.id = dd.gram_panchayat_id
+WHERE t.death_id = dd.id
+  AND t.user_id IS NULL
+  AND t.status != 'completed'
+  AND t.archived_at IS NULL;
+
+INSERT INTO permissions(name, description)
+VALUES ('task:assign', 'reassign tasks to another official')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role IN ('Admin', 'SDM')
+  AND p.name = 'task:assign'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,52 @@
+ALTER TABLE task_types
+    ADD COLUMN IF NOT EXISTS sla_days INTEGER CHECK (sla_days > 0),
+    ADD COLUMN IF NOT EXISTS sla_working_days BOOLEAN DEFAULT false NOT NULL;
+
+-- 0 until the task is overdue, then 1 for the Sachiv, 2 for the SDM and 3 for the district post holder
+ALTER TABLE task
+    ADD COLUMN IF NOT EXISTS escalation_level INTEGER DEFAULT 0 NOT NULL,
+    ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS notification(
+                                           id SERIAL PRIMARY KEY ,
+                                           user_id INTEGER REFERENCES users(id) NOT NULL ,
+                                           message TEXT NOT NULL ,
+                                           task_id INTEGER REFERENCES task(id) ,
+                                           created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                           read_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS notification_user_idx ON notification(user_id) WHERE read_at IS NULL;
+
+-- the sla of a task runs from its creation or its last reopening, whichever came last
+CREATE OR REPLACE VIEW task_sla_start AS
+SELECT t.id AS task_id,
+       greatest(t.created_at, reopen.reopened_at) AS sla_started_at
+FROM task t
+         LEFT JOIN LATERAL (SELECT max(h.created_at) AS reopened_at
+                            FROM task_status_history h
+                            WHERE h.task_id = t.id
+                              AND h.from_status = 'completed'
+                              AND h.to_status != 'completed') reopen ON true;
+
+-- days off that working day slas skip, next to saturdays and sundays
+CREATE TABLE IF NOT EXISTS holiday(
+                                      id SERIAL PRIMARY KEY ,
+                                      date DATE NOT NULL ,
+                                      name TEXT NOT NULL ,
+                                      created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                      archived_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS holiday_active_idx ON holiday(date) WHERE archived_at IS NULL;
+
+INSERT INTO permissions(name, description)
+VALUES ('task-type:manage', 'set the sla of task types and the holidays slas skip')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name = 'task-type:manage'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,56 @@
+CREATE TABLE IF NOT EXISTS task_type_prerequisite(
+                                                     id SERIAL PRIMARY KEY ,
+                                                     task_type_id INTEGER REFERENCES task_types(id) NOT NULL ,
+                                                     prerequisite_task_type_id INTEGER REFERENCES task_types(id) NOT NULL ,
+                                                     created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                                     archived_at TIMESTAMP WITH TIME ZONE ,
+                                                     CHECK (task_type_id != prerequisite_task_type_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS task_type_prerequisite_active_idx
+    ON task_type_prerequisite(task_type_id, prerequisite_task_type_id) WHERE archived_at IS NULL;
+
+-- victim compensation is paid against the death certificate
+INSERT INTO task_type_prerequisite(task_type_id, prerequisite_task_type_id)
+SELECT t.id, p.id
+FROM task_types t, task_types p
+WHERE t.name ILIKE '%compensation%'
+  AND p.name ILIKE '%certificate%'
+ON CONFLICT DO NOTHING;
+
+-- a task is blocked while a prerequisite task of the same death is open or was rejected,
+-- a prerequisite closed as not applicable does not hold anything up
+CREATE OR REPLACE VIEW task_blocked_by AS
+SELECT t.id                               AS task_id,
+       array_agg(pt.name ORDER BY pt.name) AS blocked_by
+FROM task t
+         JOIN task_type_prerequisite ttp ON ttp.task_type_id = t.task_type_id AND ttp.archived_at IS NULL
+         JOIN task_types pt ON pt.id = ttp.prerequisite_task_type_id
+         JOIN task p ON p.death_id = t.death_id AND p.task_type_id = ttp.prerequisite_task_type_id AND p.archived_at IS NULL
+WHERE NOT (p.status = 'completed' AND p.outcome IN ('completed', 'not_applicable'))
+GROUP BY t.id;
+
+-- the sla of a task runs from when it could be worked on: its creation, the completion of its last prerequisite
+-- or its last reopening, whichever came last. A task held back by a prerequisite has no sla running.
+CREATE OR REPLACE VIEW task_sla_start AS
+SELECT t.id AS task_id,
+       CASE
+           WHEN tbb.task_id IS NOT NULL THEN NULL
+           ELSE greatest(t.created_at, prerequisite.completed_at, reopen.reopened_at)
+           END AS sla_started_at
+FROM task t
+         LEFT JOIN task_blocked_by tbb ON tbb.task_id = t.id
+         LEFT JOIN LATERAL (SELECT max(p.completed_date) AS completed_at
+                            FROM task_type_prerequisite ttp
+                                     JOIN task p ON p.death_id = t.death_id AND p.task_type_id = ttp.prerequisite_task_type_id AND p.archived_at IS NULL
+                            WHERE ttp.task_type_id = t.task_type_id
+                              AND ttp.archived_at IS NULL) prerequisite ON true
+         LEFT JOIN LATERAL (SELECT max(h.created_at) AS reopened_at
+                            FROM task_status_history h
+                            WHERE h.task_id = t.id
+                              AND h.from_status = 'completed'
+                              AND h.to_status != 'completed') reopen ON true;
+
+UPDATE permissions
+SET description = 'set the sla and prerequisites of task types and the holidays slas skip'
+WHERE name = 'task-type:manage';
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS attachment(
+                                         id SERIAL PRIMARY KEY ,
+                                         death_id INTEGER REFERENCES death_details(id) NOT NULL ,
+                                         task_id INTEGER REFERENCES task(id) ,
+                                         document_type TEXT ,
+                                         file_name TEXT NOT NULL ,
+                                         content_type TEXT NOT NULL ,
+                                         size_bytes BIGINT NOT NULL ,
+                                         storage_key TEXT UNIQUE NOT NULL ,
+                                         uploaded_by INTEGER REFERENCES users(id) NOT NULL ,
+                                         created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                         archived_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS attachment_death_idx ON attachment(death_id) WHERE archived_at IS NULL;
+
+INSERT INTO permissions(name, description)
+VALUES ('attachment:delete', 'delete attachments uploaded by other officials')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name = 'attachment:delete'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,13 @@
+-- the role is kept as it was when the comment was written, officials get transferred
+CREATE TABLE IF NOT EXISTS comment(
+                                      id SERIAL PRIMARY KEY ,
+                                      death_id INTEGER REFERENCES death_details(id) NOT NULL ,
+                                      task_id INTEGER REFERENCES task(id) ,
+                                      author_id INTEGER REFERENCES users(id) NOT NULL ,
+                                      author_role TEXT NOT NULL ,
+                                      body TEXT NOT NULL CHECK (length(body) > 0) ,
+                                      created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                      archived_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS comment_death_idx ON comment(death_id) WHERE archived_at IS NULL;
@@ -0,0 +1,32 @@
+CREATE TYPE beneficiary_relation AS ENUM ('spouse', 'son', 'daughter', 'father', 'mother', 'brother', 'sister', 'other');
+
+CREATE TABLE IF NOT EXISTS beneficiary(
+                                          id SERIAL PRIMARY KEY ,
+                                          death_id INTEGER REFERENCES death_details(id) NOT NULL ,
+                                          name TEXT NOT NULL ,
+                                          relation beneficiary_relation NOT NULL ,
+                                          phone_no TEXT NOT NULL ,
+                                          aadhar_number TEXT NOT NULL ,
+                                          bank_account_number TEXT NOT NULL ,
+                                          ifsc_code TEXT NOT NULL ,
+                                          created_by INTEGER REFERENCES users(id) NOT NULL ,
+                                          created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                          updated_at TIMESTAMP WITH TIME ZONE ,
+                                          archived_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS beneficiary_death_idx ON beneficiary(death_id) WHERE archived_at IS NULL;
+
+-- the same person cannot be nominated twice for one death
+CREATE UNIQUE INDEX IF NOT EXISTS beneficiary_death_aadhar_idx ON beneficiary(death_id, aadhar_number) WHERE archived_at IS NULL;
+
+INSERT INTO permissions(name, description)
+VALUES ('beneficiary:edit', 'add, edit and remove the beneficiaries of a death')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role IN ('Admin', 'Sachiv')
+  AND p.name = 'beneficiary:edit'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,54 @@
+-- only monetary task types are sanctioned and paid out
+ALTER TABLE task_types
+    ADD COLUMN IF NOT EXISTS is_monetary BOOLEAN DEFAULT false NOT NULL;
+
+UPDATE task_types
+SET is_monetary = true
+WHERE name IN ('victim_compensation', 'insurance_policy');
+
+-- amounts are kept in paise so no rupee is lost to rounding.
+-- A sanction entered wrong is corrected in place or archived with the reason, never deleted
+CREATE TABLE IF NOT EXISTS task_sanction(
+                                            id SERIAL PRIMARY KEY ,
+                                            task_id INTEGER REFERENCES task(id) NOT NULL ,
+                                            beneficiary_id INTEGER REFERENCES beneficiary(id) NOT NULL ,
+                                            sanctioned_paise BIGINT NOT NULL CHECK (sanctioned_paise > 0) ,
+                                            sanction_order_no TEXT NOT NULL ,
+                                            sanctioned_on DATE NOT NULL ,
+                                            created_by INTEGER REFERENCES users(id) NOT NULL ,
+                                            created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                            updated_at TIMESTAMP WITH TIME ZONE ,
+                                            archived_at TIMESTAMP WITH TIME ZONE ,
+                                            archived_by INTEGER REFERENCES users(id) ,
+                                            archive_reason TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS task_sanction_beneficiary_idx ON task_sanction(task_id, beneficiary_id) WHERE archived_at IS NULL;
+
+-- a sanction is paid out in one or more instalments, the ledger is never edited
+CREATE TABLE IF NOT EXISTS payout(
+                                     id SERIAL PRIMARY KEY ,
+                                     sanction_id INTEGER REFERENCES task_sanction(id) NOT NULL ,
+                                     amount_paise BIGINT NOT NULL CHECK (amount_paise > 0) ,
+                                     paid_on DATE NOT NULL ,
+                                     payment_reference TEXT UNIQUE NOT NULL ,
+                                     created_by INTEGER REFERENCES users(id) NOT NULL ,
+                                     created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS payout_sanction_idx ON payout(sanction_id);
+
+INSERT INTO permissions(name, description)
+VALUES ('payout:manage', 'record, correct and archive sanctions and record payments of monetary tasks')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name = 'payout:manage'
+ON CONFLICT DO NOTHING;
+
+UPDATE permissions
+SET description = 'set the sla, prerequisites and monetary flag of task types and the holidays slas skip'
+WHERE name = 'task-type:manage';
@@ -0,0 +1,37 @@
+-- what registration needs to know about the deceased to decide which schemes apply
+ALTER TABLE death_details
+    ADD COLUMN IF NOT EXISTS cause_of_death TEXT,
+    ADD COLUMN IF NOT EXISTS place_of_death TEXT,
+    ADD COLUMN IF NOT EXISTS occupation TEXT,
+    ADD COLUMN IF NOT EXISTS is_bpl BOOLEAN DEFAULT false NOT NULL,
+    ADD COLUMN IF NOT EXISTS schemes TEXT[] DEFAULT '{}' NOT NULL;
+
+-- a task type applies to a death when every one of its rules matches, a task type without rules applies to all
+CREATE TABLE IF NOT EXISTS task_type_eligibility_rule(
+                                                         id SERIAL PRIMARY KEY ,
+                                                         task_type_id INTEGER REFERENCES task_types(id) NOT NULL ,
+                                                         attribute TEXT NOT NULL CHECK (attribute IN ('age', 'gender', 'cause_of_death', 'place_of_death', 'occupation', 'is_bpl', 'scheme')) ,
+                                                         operator TEXT NOT NULL CHECK (operator IN ('eq', 'neq', 'in', 'not_in', 'gte', 'lte')) ,
+                                                         rule_values TEXT[] NOT NULL CHECK (cardinality(rule_values) > 0) ,
+                                                         description TEXT ,
+                                                         created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                                         archived_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS task_type_eligibility_rule_idx ON task_type_eligibility_rule(task_type_id) WHERE archived_at IS NULL;
+
+-- the decision taken for every task type at registration, with the rules that led to it
+CREATE TABLE IF NOT EXISTS task_eligibility(
+                                               id SERIAL PRIMARY KEY ,
+                                               death_id INTEGER REFERENCES death_details(id) NOT NULL ,
+                                               task_type_id INTEGER REFERENCES task_types(id) NOT NULL ,
+                                               is_eligible BOOLEAN NOT NULL ,
+                                               reasons TEXT[] NOT NULL ,
+                                               created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS task_eligibility_death_idx ON task_eligibility(death_id);
+
+UPDATE permissions
+SET description = 'set the sla, prerequisites, eligibility rules and monetary flag of task types and the holidays slas skip'
+WHERE name = 'task-type:manage';
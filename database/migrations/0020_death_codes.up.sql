@@ -0,0 +1,49 @@
+-- code lists for the cause, place and circumstance of a death, managed by the admins
+CREATE TABLE IF NOT EXISTS death_code(
+                                         id SERIAL PRIMARY KEY ,
+                                         kind TEXT NOT NULL CHECK (kind IN ('cause', 'place', 'circumstance')) ,
+                                         code TEXT NOT NULL ,
+                                         name TEXT NOT NULL ,
+                                         created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                         archived_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS death_code_kind_code_idx ON death_code(kind, code) WHERE archived_at IS NULL;
+
+INSERT INTO death_code(kind, code, name)
+VALUES ('place', 'home', 'Home'),
+       ('place', 'hospital', 'Hospital'),
+       ('place', 'other', 'Other'),
+       ('circumstance', 'natural', 'Natural'),
+       ('circumstance', 'accident', 'Accident'),
+       ('cause', 'illness', 'Illness'),
+       ('cause', 'heart_attack', 'Heart attack'),
+       ('cause', 'road_accident', 'Road accident'),
+       ('cause', 'drowning', 'Drowning'),
+       ('cause', 'snake_bite', 'Snake bite'),
+       ('cause', 'lightning', 'Lightning'),
+       ('cause', 'old_age', 'Old age'),
+       ('cause', 'other', 'Other')
+ON CONFLICT DO NOTHING;
+
+-- deaths keep the code they were registered with, archiving a code only stops new deaths from using it
+ALTER TABLE death_details
+    ADD COLUMN IF NOT EXISTS circumstance TEXT;
+
+CREATE INDEX IF NOT EXISTS death_details_cause_idx ON death_details(cause_of_death) WHERE archived_at IS NULL;
+
+ALTER TABLE task_type_eligibility_rule
+    DROP CONSTRAINT IF EXISTS task_type_eligibility_rule_attribute_check,
+    ADD CONSTRAINT task_type_eligibility_rule_attribute_check
+        CHECK (attribute IN ('age', 'gender', 'cause_of_death', 'place_of_death', 'circumstance', 'occupation', 'is_bpl', 'scheme'));
+
+INSERT INTO permissions(name, description)
+VALUES ('death-code:manage', 'add, rename and archive the causes, places and circumstances of death')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name = 'death-code:manage'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,37 @@
+-- every version of the details of a death, version 1 is the death as registered
+CREATE TABLE IF NOT EXISTS death_version(
+                                            id SERIAL PRIMARY KEY ,
+                                            death_id INTEGER REFERENCES death_details(id) NOT NULL ,
+                                            version INTEGER NOT NULL ,
+                                            details JSONB NOT NULL ,
+                                            edited_by INTEGER REFERENCES users(id) ,
+                                            edited_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL ,
+                                            UNIQUE (death_id, version)
+);
+
+-- deaths registered before the history was kept start from what they hold today
+INSERT INTO death_version(death_id, version, details, edited_by, edited_at)
+SELECT dd.id,
+       1,
+       jsonb_build_object('name', dd.name, 'phoneNo', dd.phone_no, 'age', dd.age, 'gender', dd.gender,
+                          'aadharNumber', dd.aadhar_number, 'address', a.address, 'dateOfDeath', dd.date_of_death,
+                          'gaonId', dd.gaon_id, 'causeOfDeath', dd.cause_of_death, 'placeOfDeath', dd.place_of_death,
+                          'circumstance', dd.circumstance, 'occupation', dd.occupation, 'isBpl', dd.is_bpl,
+                          'schemes', dd.schemes),
+       dd.created_by,
+       dd.created_at
+FROM death_details dd
+         LEFT JOIN death_details_address dda on dda.death_detail_id = dd.id AND dda.archived_at IS NULL
+         LEFT JOIN address a on a.id = dda.address_id
+ON CONFLICT DO NOTHING;
+
+INSERT INTO permissions(name, description)
+VALUES ('death:edit', 'correct the details of any death in the jurisdiction after registration')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name = 'death:edit'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,17 @@
+-- why and by whom a death was archived, its tasks and reviews are archived at the same instant so a restore can find them
+ALTER TABLE death_details
+    ADD COLUMN IF NOT EXISTS archived_by INTEGER REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS archive_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS death_details_archived_idx ON death_details(archived_at) WHERE archived_at IS NOT NULL;
+
+INSERT INTO permissions(name, description)
+VALUES ('death:archive', 'archive duplicate or fraudulent deaths and restore them')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions(role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.role = 'Admin'
+  AND p.name = 'death:archive'
+ON CONFLICT DO NOTHING;
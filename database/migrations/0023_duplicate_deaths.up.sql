@@ -0,0 +1,8 @@
+-- a death registered although it looked like one already registered keeps which ones and why it was let through
+ALTER TABLE death_details
+    ADD COLUMN IF NOT EXISTS possible_duplicate_ids INTEGER[],
+    ADD COLUMN IF NOT EXISTS duplicate_justification TEXT;
+
+-- registration looks up deaths by aadhaar and by gaon and date of death
+CREATE INDEX IF NOT EXISTS death_details_aadhar_idx ON death_details(replace(aadhar_number, ' ', '')) WHERE archived_at IS NULL;
+CREATE INDEX IF NOT EXISTS death_details_gaon_date_idx ON death_details(gaon_id, date_of_death) WHERE archived_at IS NULL;
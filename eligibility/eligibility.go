@@ -0,0 +1,193 @@
+package eligibility
+
+import (
+	"errors"
+	"fmt"
+	"grampanchayat/models"
+	"strconv"
+	"strings"
+)
+
+// attributes of a death the rules can look at
+const (
+	AttributeAge          = "age"
+	AttributeGender       = "gender"
+	AttributeCauseOfDeath = "cause_of_death"
+	AttributePlaceOfDeath = "place_of_death"
+	AttributeCircumstance = "circumstance"
+	AttributeOccupation   = "occupation"
+	AttributeIsBPL        = "is_bpl"
+	AttributeScheme       = "scheme"
+)
+
+const (
+	OperatorEq    = "eq"
+	OperatorNeq   = "neq"
+	OperatorIn    = "in"
+	OperatorNotIn = "not_in"
+	OperatorGte   = "gte"
+	OperatorLte   = "lte"
+)
+
+// operators lists what every attribute can be compared with
+var operators = map[string][]string{
+	AttributeAge:          {OperatorEq, OperatorNeq, OperatorIn, OperatorNotIn, OperatorGte, OperatorLte},
+	AttributeGender:       {OperatorEq, OperatorNeq, OperatorIn, OperatorNotIn},
+	AttributeCauseOfDeath: {OperatorEq, OperatorNeq, OperatorIn, OperatorNotIn},
+	AttributePlaceOfDeath: {OperatorEq, OperatorNeq, OperatorIn, OperatorNotIn},
+	AttributeCircumstance: {OperatorEq, OperatorNeq, OperatorIn, OperatorNotIn},
+	AttributeOccupation:   {OperatorEq, OperatorNeq, OperatorIn, OperatorNotIn},
+	AttributeIsBPL:        {OperatorEq},
+	AttributeScheme:       {OperatorIn, OperatorNotIn},
+}
+
+// Validate checks a rule before it is stored, so evaluation never meets a rule it cannot read
+func Validate(rule models.EligibilityRule) error {
+	allowed, ok := operators[rule.Attribute]
+	if !ok {
+		return fmt.Errorf("unknown attribute %q", rule.Attribute)
+	}
+	if !contains(allowed, rule.Operator) {
+		return fmt.Errorf("%s cannot be compared with %q", rule.Attribute, rule.Operator)
+	}
+	if len(rule.Values) == 0 {
+		return errors.New("rule needs at least one value")
+	}
+	if rule.Operator != OperatorIn && rule.Operator != OperatorNotIn && len(rule.Values) != 1 {
+		return fmt.Errorf("%s takes exactly one value", rule.Operator)
+	}
+	for _, value := range rule.Values {
+		switch rule.Attribute {
+		case AttributeAge:
+			if _, err := strconv.Atoi(value); err != nil {
+				return fmt.Errorf("age %q is not a number", value)
+			}
+		case AttributeIsBPL:
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("is_bpl %q is not true or false", value)
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate tells whether the death meets every rule, along with one reason per rule.
+// Rules are assumed to be valid, see Validate.
+func Evaluate(rules []models.EligibilityRule, death models.DeathRegistrationRequest) (bool, []string) {
+	if len(rules) == 0 {
+		return true, []string{"no eligibility rules, applies to every death"}
+	}
+
+	isEligible := true
+	reasons := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		value, matched := match(rule, death)
+		description := rule.Description
+		if description == "" {
+			description = fmt.Sprintf("%s %s %s", rule.Attribute, rule.Operator, strings.Join(rule.Values, ", "))
+		}
+		if matched {
+			reasons = append(reasons, fmt.Sprintf("%s: met (%s)", description, value))
+		} else {
+			isEligible = false
+			reasons = append(reasons, fmt.Sprintf("%s: not met (%s)", description, value))
+		}
+	}
+	return isEligible, reasons
+}
+
+// match returns the value of the death the rule looked at and whether it matched
+func match(rule models.EligibilityRule, death models.DeathRegistrationRequest) (string, bool) {
+	switch rule.Attribute {
+	case AttributeAge:
+		return strconv.Itoa(death.Age), compareNumber(rule, death.Age)
+	case AttributeGender:
+		return death.Gender, compareText(rule, death.Gender)
+	case AttributeCauseOfDeath:
+		return death.CauseOfDeath, compareText(rule, death.CauseOfDeath)
+	case AttributePlaceOfDeath:
+		return death.PlaceOfDeath, compareText(rule, death.PlaceOfDeath)
+	case AttributeCircumstance:
+		return death.Circumstance, compareText(rule, death.Circumstance)
+	case AttributeOccupation:
+		return death.Occupation, compareText(rule, death.Occupation)
+	case AttributeIsBPL:
+		expected, _ := strconv.ParseBool(rule.Values[0])
+		return strconv.FormatBool(death.IsBPL), death.IsBPL == expected
+	case AttributeScheme:
+		isMember := false
+		for _, scheme := range death.Schemes {
+			if containsFold(rule.Values, scheme) {
+				isMember = true
+				break
+			}
+		}
+		if len(death.Schemes) == 0 {
+			return "no schemes", isMember == (rule.Operator == OperatorIn)
+		}
+		return strings.Join(death.Schemes, ", "), isMember == (rule.Operator == OperatorIn)
+	}
+	return "", false
+}
+
+func compareNumber(rule models.EligibilityRule, value int) bool {
+	numbers := make([]int, 0, len(rule.Values))
+	for _, v := range rule.Values {
+		number, _ := strconv.Atoi(v)
+		numbers = append(numbers, number)
+	}
+
+	switch rule.Operator {
+	case OperatorEq:
+		return value == numbers[0]
+	case OperatorNeq:
+		return value != numbers[0]
+	case OperatorGte:
+		return value >= numbers[0]
+	case OperatorLte:
+		return value <= numbers[0]
+	case OperatorIn, OperatorNotIn:
+		found := false
+		for _, number := range numbers {
+			if value == number {
+				found = true
+				break
+			}
+		}
+		return found == (rule.Operator == OperatorIn)
+	}
+	return false
+}
+
+// compareText ignores case, the codes come from forms typed in by hand
+func compareText(rule models.EligibilityRule, value string) bool {
+	switch rule.Operator {
+	case OperatorEq:
+		return strings.EqualFold(value, rule.Values[0])
+	case OperatorNeq:
+		return !strings.EqualFold(value, rule.Values[0])
+	case OperatorIn:
+		return containsFold(rule.Values, value)
+	case OperatorNotIn:
+		return !containsFold(rule.Values, value)
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
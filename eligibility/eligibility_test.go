@@ -0,0 +1,115 @@
+package eligibility
+
+import (
+	"grampanchayat/models"
+	"testing"
+)
+
+func rule(attribute, operator string, values ...string) models.EligibilityRule {
+	return models.EligibilityRule{Attribute: attribute, Operator: operator, Values: values}
+}
+
+func TestEvaluateWithoutRules(t *testing.T) {
+	for _, rules := range [][]models.EligibilityRule{nil, {}} {
+		isEligible, reasons := Evaluate(rules, models.DeathRegistrationRequest{})
+		if !isEligible {
+			t.Errorf("Evaluate(%v) = not eligible, want eligible", rules)
+		}
+		if len(reasons) != 1 {
+			t.Errorf("Evaluate(%v) gave %d reasons, want 1", rules, len(reasons))
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []models.EligibilityRule
+		death models.DeathRegistrationRequest
+		want  bool
+	}{
+		{"scheme in, member", []models.EligibilityRule{rule(AttributeScheme, OperatorIn, "pm_kisan")}, models.DeathRegistrationRequest{Schemes: []string{"pm_kisan"}}, true},
+		{"scheme in, other scheme", []models.EligibilityRule{rule(AttributeScheme, OperatorIn, "pm_kisan")}, models.DeathRegistrationRequest{Schemes: []string{"ujjwala"}}, false},
+		{"scheme in, no schemes", []models.EligibilityRule{rule(AttributeScheme, OperatorIn, "pm_kisan")}, models.DeathRegistrationRequest{}, false},
+		{"scheme in, any of several", []models.EligibilityRule{rule(AttributeScheme, OperatorIn, "pm_kisan", "ujjwala")}, models.DeathRegistrationRequest{Schemes: []string{"jan_dhan", "ujjwala"}}, true},
+		{"scheme in, other case", []models.EligibilityRule{rule(AttributeScheme, OperatorIn, "PM_Kisan")}, models.DeathRegistrationRequest{Schemes: []string{"pm_kisan"}}, true},
+		{"scheme not in, member", []models.EligibilityRule{rule(AttributeScheme, OperatorNotIn, "pm_kisan")}, models.DeathRegistrationRequest{Schemes: []string{"pm_kisan"}}, false},
+		{"scheme not in, other scheme", []models.EligibilityRule{rule(AttributeScheme, OperatorNotIn, "pm_kisan")}, models.DeathRegistrationRequest{Schemes: []string{"ujjwala"}}, true},
+		{"scheme not in, no schemes", []models.EligibilityRule{rule(AttributeScheme, OperatorNotIn, "pm_kisan")}, models.DeathRegistrationRequest{}, true},
+		{"age eq", []models.EligibilityRule{rule(AttributeAge, OperatorEq, "60")}, models.DeathRegistrationRequest{Age: 60}, true},
+		{"age neq", []models.EligibilityRule{rule(AttributeAge, OperatorNeq, "60")}, models.DeathRegistrationRequest{Age: 60}, false},
+		{"age gte, at the bound", []models.EligibilityRule{rule(AttributeAge, OperatorGte, "18")}, models.DeathRegistrationRequest{Age: 18}, true},
+		{"age gte, below", []models.EligibilityRule{rule(AttributeAge, OperatorGte, "18")}, models.DeathRegistrationRequest{Age: 17}, false},
+		{"age lte, at the bound", []models.EligibilityRule{rule(AttributeAge, OperatorLte, "59")}, models.DeathRegistrationRequest{Age: 59}, true},
+		{"age lte, above", []models.EligibilityRule{rule(AttributeAge, OperatorLte, "59")}, models.DeathRegistrationRequest{Age: 60}, false},
+		{"age range", []models.EligibilityRule{rule(AttributeAge, OperatorGte, "18"), rule(AttributeAge, OperatorLte, "59")}, models.DeathRegistrationRequest{Age: 40}, true},
+		{"age in", []models.EligibilityRule{rule(AttributeAge, OperatorIn, "1", "2", "3")}, models.DeathRegistrationRequest{Age: 2}, true},
+		{"age not in", []models.EligibilityRule{rule(AttributeAge, OperatorNotIn, "1", "2", "3")}, models.DeathRegistrationRequest{Age: 2}, false},
+		{"gender eq, other case", []models.EligibilityRule{rule(AttributeGender, OperatorEq, "Female")}, models.DeathRegistrationRequest{Gender: "female"}, true},
+		{"gender neq, other case", []models.EligibilityRule{rule(AttributeGender, OperatorNeq, "FEMALE")}, models.DeathRegistrationRequest{Gender: "female"}, false},
+		{"cause in, other case", []models.EligibilityRule{rule(AttributeCauseOfDeath, OperatorIn, "Accident", "snake_bite")}, models.DeathRegistrationRequest{CauseOfDeath: "accident"}, true},
+		{"cause not in, other case", []models.EligibilityRule{rule(AttributeCauseOfDeath, OperatorNotIn, "ACCIDENT")}, models.DeathRegistrationRequest{CauseOfDeath: "accident"}, false},
+		{"place eq, empty", []models.EligibilityRule{rule(AttributePlaceOfDeath, OperatorEq, "hospital")}, models.DeathRegistrationRequest{}, false},
+		{"circumstance eq", []models.EligibilityRule{rule(AttributeCircumstance, OperatorEq, "accident")}, models.DeathRegistrationRequest{Circumstance: "Accident"}, true},
+		{"occupation in", []models.EligibilityRule{rule(AttributeOccupation, OperatorIn, "farmer")}, models.DeathRegistrationRequest{Occupation: "Farmer"}, true},
+		{"bpl true", []models.EligibilityRule{rule(AttributeIsBPL, OperatorEq, "true")}, models.DeathRegistrationRequest{IsBPL: true}, true},
+		{"bpl true, not bpl", []models.EligibilityRule{rule(AttributeIsBPL, OperatorEq, "true")}, models.DeathRegistrationRequest{}, false},
+		{"bpl false", []models.EligibilityRule{rule(AttributeIsBPL, OperatorEq, "false")}, models.DeathRegistrationRequest{}, true},
+		{"every rule has to be met", []models.EligibilityRule{rule(AttributeIsBPL, OperatorEq, "true"), rule(AttributeAge, OperatorGte, "60")}, models.DeathRegistrationRequest{IsBPL: true, Age: 45}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			isEligible, reasons := Evaluate(test.rules, test.death)
+			if isEligible != test.want {
+				t.Errorf("Evaluate() = %v, want %v, reasons %v", isEligible, test.want, reasons)
+			}
+			if len(reasons) != len(test.rules) {
+				t.Errorf("Evaluate() gave %d reasons for %d rules", len(reasons), len(test.rules))
+			}
+		})
+	}
+}
+
+func TestEvaluateReasonUsesDescription(t *testing.T) {
+	bpl := rule(AttributeIsBPL, OperatorEq, "true")
+	bpl.Description = "below poverty line"
+
+	_, reasons := Evaluate([]models.EligibilityRule{bpl}, models.DeathRegistrationRequest{})
+	if want := "below poverty line: not met (false)"; reasons[0] != want {
+		t.Errorf("reason = %q, want %q", reasons[0], want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.EligibilityRule
+		wantErr bool
+	}{
+		{"age gte", rule(AttributeAge, OperatorGte, "18"), false},
+		{"age in", rule(AttributeAge, OperatorIn, "1", "2"), false},
+		{"gender eq", rule(AttributeGender, OperatorEq, "female"), false},
+		{"scheme not in", rule(AttributeScheme, OperatorNotIn, "pm_kisan", "ujjwala"), false},
+		{"bpl eq", rule(AttributeIsBPL, OperatorEq, "false"), false},
+		{"unknown attribute", rule("income", OperatorEq, "1000"), true},
+		{"unknown operator", rule(AttributeAge, "between", "18"), true},
+		{"text with a number operator", rule(AttributeGender, OperatorGte, "female"), true},
+		{"scheme with eq", rule(AttributeScheme, OperatorEq, "pm_kisan"), true},
+		{"bpl with in", rule(AttributeIsBPL, OperatorIn, "true"), true},
+		{"no values", rule(AttributeAge, OperatorIn), true},
+		{"eq with several values", rule(AttributeGender, OperatorEq, "male", "female"), true},
+		{"age not a number", rule(AttributeAge, OperatorGte, "eighteen"), true},
+		{"age in with one not a number", rule(AttributeAge, OperatorIn, "18", "x"), true},
+		{"bpl not a bool", rule(AttributeIsBPL, OperatorEq, "yes"), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(test.rule)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
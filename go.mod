@@ -11,12 +11,16 @@ require (
 	github.com/google/uuid v1.4.0
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/lib/pq v1.10.9
+	github.com/pquerna/otp v1.2.0
 	github.com/rs/cors v1.10.1
 	github.com/sirupsen/logrus v1.9.3
+	github.com/thoas/go-funk v0.9.3
+	golang.org/x/crypto v0.17.0
 	golang.org/x/sync v0.5.0
 )
 
 require (
+	github.com/boombuler/barcode v1.0.1-0.20190219062509-6c824513bacc // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/robfig/cron/v3 v3.0.1 // indirect
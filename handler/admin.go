@@ -1,40 +1,43 @@
 package handler
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
-	_ "github.com/go-chi/chi/v5"
-	"github.com/golang-jwt/jwt"
+	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 	"grampanchayat/database"
 	"grampanchayat/database/helper"
+	"grampanchayat/jwtkeys"
 	"grampanchayat/models"
+	"grampanchayat/sms"
 	"grampanchayat/utilities"
-	"io/ioutil"
-	"math/rand"
+	"math/big"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
-var JwtKey = []byte("secret_key")
-
-var accountSid = os.Getenv("TWILIO_ACCOUNT_SID")
-var authToken = os.Getenv("TWILIO_AUTH_TOKEN")
-var VerifyServiceSid = os.Getenv("VerifyServiceSid")
-var SMSAuthorizationKey = "WOFC7oiwS1TdnzbDMcsIx4hUemLuGHZjr8RtBpyV9v03qJklQPorFvS7I42KypXh03dUjOq6RBi9DwH1"
+// SigningKeys signs and verifies access tokens, loaded from configuration at startup
+var SigningKeys *jwtkeys.KeySet
 
 const defaultLimit = 100
 
-var client = twilio.NewRestClientWithParams(twilio.ClientParams{
-	Username: accountSid,
-	Password: authToken,
-})
+const (
+	otpMaxAttempts          = 5
+	otpMaxFailuresPerWindow = 10
+	otpLockoutWindow        = 30 * time.Minute
+	otpResendCooldown       = time.Minute
+	otpMaxSendsPerHour      = 5
+	otpSentMessage          = "if the number is registered, an otp has been sent to it"
+)
+
+// OTPSender delivers login otps, selected from configuration at startup
+var OTPSender sms.OTPSender
 
 func SendOTP(w http.ResponseWriter, r *http.Request) {
 	var sendOTP models.SendOTP
@@ -50,66 +53,89 @@ func SendOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := SendSms(sendOTP.Phone, r)
+	stats, err := helper.GetOTPStats(sendOTP.Phone, otpLockoutWindow)
 	if err != nil {
-		utilities.HandlerError(w, http.StatusInternalServerError, "SendOTP: Unable to send otp. %v", err)
+		utilities.HandlerError(w, http.StatusInternalServerError, "SendOTP: cannot get otp stats:", err)
 		return
 	}
-}
 
-func SendSms(toPhone string, req *http.Request) error {
-	var otp string
-	if os.Getenv("BRANCH") == "DEV" {
-		otp = "9999"
-	} else {
-		var randomCodes = [...]byte{
-			'1', '2', '3', '4', '5', '6', '7', '8', '9', '0',
-		}
+	if stats.FailedAttempts >= otpMaxFailuresPerWindow {
+		utilities.HandlerError(w, http.StatusTooManyRequests, "too many failed attempts, please try again later", errors.New("SendOTP: phone is locked out"))
+		return
+	}
 
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	if stats.SentLastHour >= otpMaxSendsPerHour || (stats.LastSentAt.Valid && time.Since(stats.LastSentAt.Time) < otpResendCooldown) {
+		utilities.HandlerError(w, http.StatusTooManyRequests, "please wait before requesting another otp", errors.New("SendOTP: otp resend throttled"))
+		return
+	}
 
-		pwd := make([]byte, 4)
+	// the otp is stored for unregistered numbers as well so throttling and the response
+	// cannot be used to tell which numbers belong to officials, it is only delivered to registered ones
+	isRegistered := true
+	_, err = helper.FetchUserIDAndRole(sendOTP.Phone)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			utilities.HandlerError(w, http.StatusInternalServerError, "SendOTP: FetchUserIDAndRole:", err)
+			return
+		}
+		isRegistered = false
+	}
 
-		for j := 0; j < 4; j++ {
-			index := r.Int() % len(randomCodes)
-			pwd[j] = randomCodes[index]
+	err = SendSms(sendOTP.Phone, isRegistered)
+	if err != nil {
+		// a failed delivery only happens for registered numbers, answering it differently would give them away
+		if errors.Is(err, sms.ErrDeliveryFailed) {
+			logrus.Printf("SendOTP: unable to deliver otp:%v", err)
+		} else {
+			utilities.HandlerError(w, http.StatusInternalServerError, "SendOTP: Unable to send otp. %v", err)
+			return
 		}
-		otp = string(pwd)
+	}
 
-		message := "This%20is%20your%20OTP : "
+	message := otpSentMessage
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SendOTP: EncoderError", err)
+		return
+	}
+}
 
-		url := fmt.Sprintf("https://www.fast2sms.com/dev/bulkV2?sender_id=TXTIND&message=%v%v&route=v3&numbers=%v", message, otp, toPhone)
+func SendSms(toPhone string, deliver bool) error {
+	otp, err := generateOTP()
+	if err != nil {
+		logrus.Printf("SendSms: cannot generate otp:%v", err)
+		return err
+	}
 
-		payload := strings.NewReader(otp)
+	// saved before delivery, so a failed delivery counts towards the throttling like any other send
+	err = helper.AddOtp(toPhone, otp)
+	if err != nil {
+		logrus.Printf("SendSms: cannot save otp:%v", err)
+		return err
+	}
 
-		client := &http.Client{}
-		req, err := http.NewRequest(http.MethodPost, url, payload)
-		if err != nil {
-			logrus.Printf("SendSms: unable to create request. %v", err)
-			return err
-		}
-		req.Header.Add("Authorization", SMSAuthorizationKey)
-		res, err := client.Do(req)
+	if deliver {
+		err = OTPSender.SendOTP(toPhone, otp)
 		if err != nil {
-			logrus.Printf("SendSms: unable to get response. %v", err)
+			logrus.Printf("SendSms: cannot send sms:%v", err)
 			return err
 		}
-		defer res.Body.Close()
+	}
+
+	return nil
+}
+
+func generateOTP() (string, error) {
+	pwd := make([]byte, 4)
 
-		body, err := ioutil.ReadAll(res.Body)
+	for j := 0; j < 4; j++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
 		if err != nil {
-			logrus.Printf("SendSms: unable to read response bidy. %v", err)
-			return err
+			return "", err
 		}
-		fmt.Println(string(body))
+		pwd[j] = byte('0' + n.Int64())
 	}
-	err := helper.AddOtp(toPhone, otp)
-	if err != nil {
-		logrus.Printf("SendSms: cannot send sms:%v", err)
-		return err
-	}
-
-	return nil
+	return string(pwd), nil
 }
 
 func LoginWithOTP(w http.ResponseWriter, r *http.Request) {
@@ -131,61 +157,156 @@ func LoginWithOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	stats, err := helper.GetOTPStats(loginNumber.Phone, otpLockoutWindow)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "LoginWithOTP: cannot get otp stats:", err)
+		return
+	}
+
+	if stats.FailedAttempts >= otpMaxFailuresPerWindow {
+		utilities.HandlerError(w, http.StatusTooManyRequests, "too many failed attempts, please try again later", errors.New("LoginWithOTP: phone is locked out"))
+		return
+	}
+
 	storedOTP, err := helper.FetchOTP(loginNumber.Phone)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			utilities.HandlerError(w, http.StatusBadRequest, "invalid otp", errors.New("no active otp"))
+			return
+		}
 		utilities.HandlerError(w, http.StatusInternalServerError, "FetchOTP: cannot get otp:", err)
 		return
 	}
 
-	if storedOTP != loginNumber.OTP {
+	if subtle.ConstantTimeCompare([]byte(storedOTP.OTP), []byte(loginNumber.OTP)) != 1 {
+		err = helper.IncrementOTPAttempts(storedOTP.ID, otpMaxAttempts)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusInternalServerError, "LoginWithOTP: IncrementOTPAttempts:", err)
+			return
+		}
 		utilities.HandlerError(w, http.StatusBadRequest, "invalid otp", errors.New("invalid otp"))
 		return
 	}
 
+	consumed, err := helper.ConsumeOTP(storedOTP.ID, loginNumber.Phone)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "LoginWithOTP: ConsumeOTP:", err)
+		return
+	}
+	if !consumed {
+		utilities.HandlerError(w, http.StatusBadRequest, "invalid otp", errors.New("otp already used"))
+		return
+	}
+
 	userCredentials, fetchErr := helper.FetchUserIDAndRole(loginNumber.Phone)
 	if fetchErr != nil {
 		if fetchErr == sql.ErrNoRows {
-			_, err := w.Write([]byte("ERROR: Wrong details"))
-			if err != nil {
-				return
-			}
-			utilities.HandlerError(w, http.StatusBadRequest, "FetchUserIDAndRole:", fetchErr)
+			utilities.HandlerError(w, http.StatusBadRequest, "invalid otp", fetchErr)
 			return
 		}
 		utilities.HandlerError(w, http.StatusInternalServerError, "FetchUserIDAndRole:", fetchErr)
 		return
 	}
 
-	expiresAt := time.Now().Add(60 * time.Hour)
+	refreshSecret, refreshSecretHash, err := newSecretToken()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "LoginWithOTP: cannot create refresh token:", err)
+		return
+	}
+
+	sessionID, err := helper.CreateSession(userCredentials.ID, refreshSecretHash, time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "LoginWithOTP: CreateSession:", err)
+		return
+	}
+
+	writeTokens(w, userCredentials, sessionID, refreshSecret)
+}
 
-	claims := &models.Claims{
-		ID:   userCredentials.ID,
-		Role: userCredentials.Role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expiresAt.Unix(),
-		},
+func Logout(w http.ResponseWriter, r *http.Request) {
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "Logout: Context for details:", errors.New("cannot get context details"))
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(JwtKey)
+	err := helper.ArchiveSession(contextValues.SessionID)
 	if err != nil {
-		utilities.HandlerError(w, http.StatusInternalServerError, "TokenString: cannot create token string:", err)
+		utilities.HandlerError(w, http.StatusInternalServerError, "Logout: cannot end session:", err)
 		return
 	}
 
-	err = helper.CreateSession(claims)
+	message := "successfully logged out"
+	err = utilities.Encoder(w, &message)
 	if err != nil {
-		utilities.HandlerError(w, http.StatusInternalServerError, "LoginWithOTP: CreateSession:", err)
+		utilities.HandlerError(w, http.StatusInternalServerError, "Logout: EncoderError", err)
 		return
 	}
+}
 
-	userOutboundData := make(map[string]interface{})
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "LogoutAll: Context for details:", errors.New("cannot get context details"))
+		return
+	}
 
-	userOutboundData["token"] = tokenString
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		_, err := helper.ArchiveUserSessions(contextValues.ID, tx)
+		return err
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "LogoutAll: cannot end sessions:", err)
+		return
+	}
+
+	message := "successfully logged out from all devices"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "LogoutAll: EncoderError", err)
+		return
+	}
+}
+
+// RevokeUserSessions lets an admin end every session of an official, e.g. when their phone is lost
+func RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "RevokeUserSessions: cannot get user id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "RevokeUserSessions: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	var revoked int64
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		revoked, err = helper.ArchiveUserSessions(userID, tx)
+		if err != nil {
+			return err
+		}
+
+		after, err := json.Marshal(map[string]int64{"revokedSessions": revoked})
+		if err != nil {
+			return err
+		}
+		return helper.AddAuditLog(contextValues, utilities.AuditSessionsRevoke, utilities.EntityUser, userID, nil, after, tx)
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "RevokeUserSessions: cannot end sessions:", err)
+		return
+	}
+
+	userOutboundData := make(map[string]int64)
+	userOutboundData["revokedSessions"] = revoked
 
 	err = utilities.Encoder(w, userOutboundData)
 	if err != nil {
-		utilities.HandlerError(w, http.StatusInternalServerError, "Login: EncoderError:", err)
+		utilities.HandlerError(w, http.StatusInternalServerError, "RevokeUserSessions: EncoderError", err)
 		return
 	}
 }
@@ -279,6 +400,15 @@ func deathFilters(r *http.Request) (models.DeathFilter, error) {
 
 	}
 
+	outcomes := strings.Trim(strings.Replace(r.URL.Query().Get("outcome"), "'", "", -1), "[]")
+	if outcomes != "" {
+		filtersCheck.Outcome = strings.Split(outcomes, ",")
+	}
+
+	filtersCheck.CauseOfDeath = codeFilter(r, "causeOfDeath")
+	filtersCheck.PlaceOfDeath = codeFilter(r, "placeOfDeath")
+	filtersCheck.Circumstance = codeFilter(r, "circumstance")
+
 	tasks := r.URL.Query().Get("taskName")
 	tasks = strings.Replace(tasks, "'", "", -1)
 	if tasks != "" {
@@ -344,8 +474,43 @@ func deathFilters(r *http.Request) (models.DeathFilter, error) {
 	filtersCheck.Limit = limit
 	filtersCheck.Page = page
 
+	// the visible part of the district comes from the caller, never from the query params
+	filtersCheck.Jurisdiction, err = callerJurisdiction(r)
+	if err != nil {
+		return filtersCheck, err
+	}
+
 	return filtersCheck, nil
 }
+
+// codeFilter reads a list of death codes sent like the outcomes, as [road_accident,drowning]
+func codeFilter(r *http.Request, key string) []string {
+	list := strings.Trim(strings.Replace(r.URL.Query().Get(key), "'", "", -1), "[]")
+	if list == "" {
+		return nil
+	}
+	codes := make([]string, 0)
+	for _, code := range strings.Split(list, ",") {
+		codes = append(codes, normaliseDeathCode(code))
+	}
+	return codes
+}
+
+func callerJurisdiction(r *http.Request) (models.Jurisdiction, error) {
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		return models.Jurisdiction{}, errors.New("cannot get context details")
+	}
+	return helper.GetJurisdiction(contextValues.ID, contextValues.Role)
+}
+
+// auditNewUser records an official added along with their post, nothing when the phone number already belonged to them
+func auditNewUser(actor models.ContextValues, existing models.UserAndRoleID, userID int, tx *sqlx.Tx) error {
+	if existing.UserID != 0 {
+		return nil
+	}
+	return helper.AuditCreate(actor, utilities.AuditUserAdd, utilities.EntityUser, userID, tx)
+}
 func filters(r *http.Request) (models.FiltersCheck, error) {
 	filtersCheck := models.FiltersCheck{}
 	isSearched := false
@@ -395,7 +560,19 @@ func AddRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := helper.AddRole(roleDetails)
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddRole: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		roleID, err := helper.AddRole(roleDetails, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AuditCreate(contextValues, utilities.AuditRoleAdd, utilities.EntityRole, roleID, tx)
+	})
 	if err != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "AddRole: cannot add role:", err)
 		return
@@ -410,7 +587,27 @@ func BulkAddRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	roleIds, err := helper.BulkAddRole(roleDetails)
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "BulkAddRole: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	var roleIds []int
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		roleIds, err = helper.BulkAddRole(roleDetails, tx)
+		if err != nil {
+			return err
+		}
+		for _, roleID := range roleIds {
+			err = helper.AuditCreate(contextValues, utilities.AuditRoleAdd, utilities.EntityRole, roleID, tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "AddRole: cannot add role:", err)
 		return
@@ -431,6 +628,12 @@ func AddGaon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddGaon: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
 	// transaction started
 	txErr := database.Tx(func(tx *sqlx.Tx) error {
 		userAndRoleID, err := helper.GetUserByPhoneNo(userDetails.LekhPalPhone, tx)
@@ -443,11 +646,21 @@ func AddGaon(w http.ResponseWriter, r *http.Request) {
 			return err
 		}
 
+		err = auditNewUser(contextValues, userAndRoleID, userID, tx)
+		if err != nil {
+			return err
+		}
+
 		gaonID, err := helper.AddGaon(userDetails, tx)
 		if err != nil {
 			return err
 		}
 
+		err = helper.AuditCreate(contextValues, utilities.AuditGaonAdd, utilities.EntityGaon, gaonID, tx)
+		if err != nil {
+			return err
+		}
+
 		err = helper.AddUserGaon(userID, gaonID, tx)
 		return err
 	})
@@ -466,6 +679,12 @@ func AddSdm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddSdm: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
 	// transaction started
 	txErr := database.Tx(func(tx *sqlx.Tx) error {
 		userAndRoleID, err := helper.GetUserByPhoneNo(userDetails.PhoneNo, tx)
@@ -478,11 +697,21 @@ func AddSdm(w http.ResponseWriter, r *http.Request) {
 			return err
 		}
 
+		err = auditNewUser(contextValues, userAndRoleID, userID, tx)
+		if err != nil {
+			return err
+		}
+
 		tehsilID, err := helper.AddTehsil(userDetails.Tehsil, tx)
 		if err != nil {
 			return err
 		}
 
+		err = helper.AuditCreate(contextValues, utilities.AuditTehsilAdd, utilities.EntityTehsil, tehsilID, tx)
+		if err != nil {
+			return err
+		}
+
 		err = helper.AddUserTehsil(userID, tehsilID, tx)
 		return err
 	})
@@ -493,6 +722,52 @@ func AddSdm(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
+func AddBlockOfficer(w http.ResponseWriter, r *http.Request) {
+	var userDetails models.BlockOfficerCreateRequest
+	decoderErr := utilities.Decoder(r, &userDetails)
+	if decoderErr != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddBlockOfficer: Decoder error:", decoderErr)
+		return
+	}
+
+	if userDetails.BlockID == 0 {
+		utilities.HandlerError(w, http.StatusBadRequest, "block cannot be empty", errors.New("AddBlockOfficer: block id cannot be empty"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddBlockOfficer: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	// transaction started
+	txErr := database.Tx(func(tx *sqlx.Tx) error {
+		userAndRoleID, err := helper.GetUserByPhoneNo(userDetails.PhoneNo, tx)
+		if err != nil {
+			return err
+		}
+
+		userID, err := helper.AddUser(userDetails.Name, userDetails.PhoneNo, utilities.BlockOfficer, userAndRoleID, tx)
+		if err != nil {
+			return err
+		}
+
+		err = auditNewUser(contextValues, userAndRoleID, userID, tx)
+		if err != nil {
+			return err
+		}
+
+		err = helper.AddUserBlock(userID, userDetails.BlockID, tx)
+		return err
+	})
+	if txErr != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddBlockOfficer: transaction error:", txErr)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
 func AddGramPanchayatInformation(w http.ResponseWriter, r *http.Request) {
 	var userDetails models.GramPanchayatCreateRequest
 	decoderErr := utilities.Decoder(r, &userDetails)
@@ -504,6 +779,12 @@ func AddGramPanchayatInformation(w http.ResponseWriter, r *http.Request) {
 		utilities.HandlerError(w, http.StatusBadRequest, "Sahayak and Sachiv cannot have same phone no.", errors.New("sahayak and sachiv same phone no"))
 		return
 	}
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddGramPanchayatInformation: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
 	txErr := database.Tx(func(tx *sqlx.Tx) error {
 		//TODO: We can have multiple gram panchayat with same Sachiv. Please check for that.
 		userAndRoleID, err := helper.GetUserByPhoneNo(userDetails.SachivPhoneNo, tx)
@@ -516,6 +797,11 @@ func AddGramPanchayatInformation(w http.ResponseWriter, r *http.Request) {
 			return err
 		}
 
+		err = auditNewUser(contextValues, userAndRoleID, sachivID, tx)
+		if err != nil {
+			return err
+		}
+
 		userAndRoleID, err = helper.GetUserByPhoneNo(userDetails.SahayakPhone, tx)
 		if err != nil {
 			return err
@@ -526,11 +812,21 @@ func AddGramPanchayatInformation(w http.ResponseWriter, r *http.Request) {
 			return err
 		}
 
+		err = auditNewUser(contextValues, userAndRoleID, sahayakID, tx)
+		if err != nil {
+			return err
+		}
+
 		gramPanchayatID, err := helper.AddGramPanchayat(userDetails.GramPanchayat, userDetails.TehsilID, userDetails.BlockID, tx)
 		if err != nil {
 			return err
 		}
 
+		err = helper.AuditCreate(contextValues, utilities.AuditGramPanchayatAdd, utilities.EntityGramPanchayat, gramPanchayatID, tx)
+		if err != nil {
+			return err
+		}
+
 		err = helper.AddUserGramPanchayat(sachivID, gramPanchayatID, tx)
 		if err != nil {
 			return err
@@ -683,11 +979,143 @@ func GetTasks(resp http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func GetTotalDeaths(w http.ResponseWriter, _ *http.Request) {
+// SetTaskTypeSLA sets the number of days a task of the type should be done in, 0 removes the sla
+func SetTaskTypeSLA(w http.ResponseWriter, r *http.Request) {
+	taskTypeID, err := strconv.Atoi(chi.URLParam(r, "taskTypeID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "SetTaskTypeSLA: cannot get task type id", err)
+		return
+	}
+
+	var sla models.TaskTypeSLA
+	err = utilities.Decoder(r, &sla)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "SetTaskTypeSLA: Decoder error:", err)
+		return
+	}
+
+	if sla.SLADays < 0 {
+		utilities.HandlerError(w, http.StatusBadRequest, "sla days cannot be negative", errors.New("negative sla days"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetTaskTypeSLA: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditTaskTypeSLASet, utilities.EntityTaskType, taskTypeID, tx, func() error {
+			return helper.SetTaskTypeSLA(taskTypeID, sla, tx)
+		})
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetTaskTypeSLA: cannot set sla", err)
+		return
+	}
+
+	message := "successfully set sla"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetTaskTypeSLA: EncoderError", err)
+		return
+	}
+}
+
+// SetTaskTypeMonetary marks whether tasks of the type are sanctioned and paid out, sanctions already recorded stay
+func SetTaskTypeMonetary(w http.ResponseWriter, r *http.Request) {
+	taskTypeID, err := strconv.Atoi(chi.URLParam(r, "taskTypeID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "SetTaskTypeMonetary: cannot get task type id", err)
+		return
+	}
+
+	var monetary models.TaskTypeMonetary
+	err = utilities.Decoder(r, &monetary)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "SetTaskTypeMonetary: Decoder error:", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetTaskTypeMonetary: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditTaskTypeMonetarySet, utilities.EntityTaskType, taskTypeID, tx, func() error {
+			return helper.SetTaskTypeMonetary(taskTypeID, monetary.IsMonetary, tx)
+		})
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetTaskTypeMonetary: cannot set monetary", err)
+		return
+	}
+
+	message := "successfully set monetary"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetTaskTypeMonetary: EncoderError", err)
+		return
+	}
+}
+
+// SetTaskTypePrerequisites replaces the task types a task of this type has to wait for
+func SetTaskTypePrerequisites(w http.ResponseWriter, r *http.Request) {
+	taskTypeID, err := strconv.Atoi(chi.URLParam(r, "taskTypeID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "SetTaskTypePrerequisites: cannot get task type id", err)
+		return
+	}
+
+	var prerequisites models.TaskTypePrerequisites
+	err = utilities.Decoder(r, &prerequisites)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "SetTaskTypePrerequisites: Decoder error:", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetTaskTypePrerequisites: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditTaskTypePrereqSet, utilities.EntityTaskTypePrereqs, taskTypeID, tx, func() error {
+			return helper.SetTaskTypePrerequisites(taskTypeID, prerequisites.PrerequisiteIDs, tx)
+		})
+	})
+	if err != nil {
+		if errors.Is(err, helper.ErrPrerequisiteCycle) {
+			utilities.HandlerError(w, http.StatusBadRequest, "prerequisites cannot form a cycle", err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetTaskTypePrerequisites: cannot set prerequisites", err)
+		return
+	}
+
+	message := "successfully set prerequisites"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetTaskTypePrerequisites: EncoderError", err)
+		return
+	}
+}
+
+func GetTotalDeaths(w http.ResponseWriter, r *http.Request) {
+	jurisdiction, err := callerJurisdiction(r)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTotalDeaths: cannot get jurisdiction", err)
+		return
+	}
+
 	date := time.Now()
 	month := date.Month()
 	year, week := date.ISOWeek()
-	TotalDeaths, err := helper.GetDeathCount(month, year, week)
+	TotalDeaths, err := helper.GetDeathCount(month, year, week, jurisdiction)
 	if err != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "GetTotalDeaths: Failed to get total no of deaths for month, week and today", err)
 		return
@@ -762,6 +1190,11 @@ func GetDeathDetailsAdmin(w http.ResponseWriter, r *http.Request) {
 		utilities.HandlerError(w, http.StatusInternalServerError, "cannot get death details", err)
 		return
 	}
+	holidays, err := helper.GetHolidayCalendar()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathDetailsAdmin: cannot get holidays", err)
+		return
+	}
 	deathDetailsOutput := make([]models.DeathDetailsOutput, 0)
 	for i := range deathDetails {
 		var out []models.TaskDetail
@@ -770,6 +1203,7 @@ func GetDeathDetailsAdmin(w http.ResponseWriter, r *http.Request) {
 			utilities.HandlerError(w, http.StatusInternalServerError, "GetDeaths: UnMarshal", err)
 			return
 		}
+		setTaskFlags(out, holidays)
 		var registeredByDetails models.UserInfo
 		if deathDetails[i].CreatedBy != 0 {
 			registeredByDetails, err = helper.GetUserInfo(deathDetails[i].CreatedBy)
@@ -800,12 +1234,21 @@ func GetDeathDetailsAdmin(w http.ResponseWriter, r *http.Request) {
 			TehsilName:        deathDetails[i].TehsilName,
 			BlockId:           deathDetails[i].BlockId,
 			BlockName:         deathDetails[i].BlockName,
+			CauseOfDeath:      deathDetails[i].CauseOfDeath,
+			PlaceOfDeath:      deathDetails[i].PlaceOfDeath,
+			Circumstance:      deathDetails[i].Circumstance,
 			TaskDetails:       out,
 		}
 		deathDetailsOutput = append(deathDetailsOutput, deathDetailsOut)
 
 	}
 
+	err = setDeathComments(deathDetailsOutput)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathDetailsAdmin: cannot get comments", err)
+		return
+	}
+
 	err = utilities.Encoder(w, deathDetailsOutput)
 	if err != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeaths: EncoderError", err)
@@ -821,19 +1264,31 @@ func EditGramPanchayat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditGramPanchayat: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
 	txErr := database.Tx(func(tx *sqlx.Tx) error {
-		err := helper.EditGramPanchayat(gramPanchayatDetails, tx)
+		err := helper.AuditChange(contextValues, utilities.AuditGramPanchayatEdit, utilities.EntityGramPanchayat, gramPanchayatDetails.GramPanchayatID, tx, func() error {
+			return helper.EditGramPanchayat(gramPanchayatDetails, tx)
+		})
 		if err != nil {
 			utilities.HandlerError(w, http.StatusInternalServerError, "EditGramPanchayat: cannot edit gramPanchayat:", err)
 			return err
 		}
 
-		err = helper.EditUser(gramPanchayatDetails.SachivName, gramPanchayatDetails.SachivPhoneNo, gramPanchayatDetails.SachivID, tx)
+		err = helper.AuditChange(contextValues, utilities.AuditUserEdit, utilities.EntityUser, gramPanchayatDetails.SachivID, tx, func() error {
+			return helper.EditUser(gramPanchayatDetails.SachivName, gramPanchayatDetails.SachivPhoneNo, gramPanchayatDetails.SachivID, tx)
+		})
 		if err != nil {
 			return err
 		}
 
-		err = helper.EditUser(gramPanchayatDetails.SahayakName, gramPanchayatDetails.SahayakPhoneNo, gramPanchayatDetails.SahayakID, tx)
+		err = helper.AuditChange(contextValues, utilities.AuditUserEdit, utilities.EntityUser, gramPanchayatDetails.SahayakID, tx, func() error {
+			return helper.EditUser(gramPanchayatDetails.SahayakName, gramPanchayatDetails.SahayakPhoneNo, gramPanchayatDetails.SahayakID, tx)
+		})
 		if err != nil {
 			return err
 		}
@@ -855,15 +1310,24 @@ func EditTehsil(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditTehsil: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
 	txErr := database.Tx(func(tx *sqlx.Tx) error {
-		err := helper.EditTehsil(tehsilDetails, tx)
+		err := helper.AuditChange(contextValues, utilities.AuditTehsilEdit, utilities.EntityTehsil, tehsilDetails.TehsilID, tx, func() error {
+			return helper.EditTehsil(tehsilDetails, tx)
+		})
 		if err != nil {
 			utilities.HandlerError(w, http.StatusInternalServerError, "EditGramPanchayat: cannot edit gramPanchayat:", err)
 			return err
 		}
 
-		err = helper.EditUser(tehsilDetails.Name, tehsilDetails.PhoneNo, tehsilDetails.UserID, tx)
-		return err
+		return helper.AuditChange(contextValues, utilities.AuditUserEdit, utilities.EntityUser, tehsilDetails.UserID, tx, func() error {
+			return helper.EditUser(tehsilDetails.Name, tehsilDetails.PhoneNo, tehsilDetails.UserID, tx)
+		})
 	})
 	if txErr != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "AddGramPanchayatInformation: AddGramPanchayat:", txErr)
@@ -892,7 +1356,21 @@ func AddBlock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := helper.AddBlock(blockDetail)
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddBlock: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	var id int
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		id, err = helper.AddBlock(blockDetail, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AuditCreate(contextValues, utilities.AuditBlockAdd, utilities.EntityBlock, id, tx)
+	})
 	if err != nil {
 		utilities.HandlerError(w, http.StatusBadRequest, "AddBlock: Failed to add block:", decoderErr)
 		return
@@ -912,7 +1390,17 @@ func EditBlock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := helper.UpdateBlock(blockDetail)
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditBlock: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditBlockEdit, utilities.EntityBlock, blockDetail.BlockID, tx, func() error {
+			return helper.UpdateBlock(blockDetail, tx)
+		})
+	})
 	if err != nil {
 		utilities.HandlerError(w, http.StatusBadRequest, "EditBlock: Failed to update block:", decoderErr)
 		return
@@ -931,6 +1419,11 @@ func FetchDeathReview(w http.ResponseWriter, r *http.Request) {
 		utilities.HandlerError(w, http.StatusInternalServerError, "FetchDeathReview: failed to get death review details", err)
 		return
 	}
+	holidays, err := helper.GetHolidayCalendar()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "FetchDeathReview: cannot get holidays", err)
+		return
+	}
 	deathDetailsOutput := make([]models.DeathDetailsOutput, 0)
 	for i := range deathDetails {
 		out := make([]models.TaskDetail, 0)
@@ -939,6 +1432,7 @@ func FetchDeathReview(w http.ResponseWriter, r *http.Request) {
 			utilities.HandlerError(w, http.StatusInternalServerError, "FetchDeathReview: UnMarshal", err)
 			return
 		}
+		setTaskFlags(out, holidays)
 		var reviewerDetail models.UserInfo
 		if deathDetails[i].ReviewedBy.Int64 != 0 {
 			reviewerDetail, err = helper.GetUserInfo(int(deathDetails[i].ReviewedBy.Int64))
@@ -976,6 +1470,9 @@ func FetchDeathReview(w http.ResponseWriter, r *http.Request) {
 			TehsilName:        deathDetails[i].TehsilName,
 			BlockId:           deathDetails[i].BlockId,
 			BlockName:         deathDetails[i].BlockName,
+			CauseOfDeath:      deathDetails[i].CauseOfDeath,
+			PlaceOfDeath:      deathDetails[i].PlaceOfDeath,
+			Circumstance:      deathDetails[i].Circumstance,
 			TaskDetails:       out,
 			IsReviewed:        deathDetails[i].IsReviewed,
 			Comment:           deathDetails[i].Comment,
@@ -987,6 +1484,13 @@ func FetchDeathReview(w http.ResponseWriter, r *http.Request) {
 		deathDetailsOutput = append(deathDetailsOutput, deathDetailsOut)
 
 	}
+
+	err = setDeathComments(deathDetailsOutput)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "FetchDeathReview: unable to get comments", err)
+		return
+	}
+
 	encErr := utilities.Encoder(w, deathDetailsOutput)
 	if encErr != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "FetchDeathReview: Failed to encode output", encErr)
@@ -1014,7 +1518,27 @@ func ReviewDeathDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := helper.ReviewDeathDetails(deathDetailsReview, contextValues.ID)
+	jurisdiction, err := callerJurisdiction(r)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ReviewDeathDetails: cannot get jurisdiction", err)
+		return
+	}
+
+	inJurisdiction, err := helper.IsDeathInJurisdiction(deathDetailsReview.DeathID, jurisdiction)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ReviewDeathDetails: cannot check jurisdiction", err)
+		return
+	}
+	if !inJurisdiction {
+		utilities.HandlerError(w, http.StatusForbidden, "death is outside your jurisdiction", errors.New("death outside jurisdiction"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditDeathReview, utilities.EntityDeathReview, deathDetailsReview.ID, tx, func() error {
+			return helper.ReviewDeathDetails(deathDetailsReview, contextValues.ID, tx)
+		})
+	})
 	if err != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "ReviewDeathDetails: cannot review death", err)
 		return
@@ -1044,15 +1568,24 @@ func EditGaon(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditGaon: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
 	txErr := database.Tx(func(tx *sqlx.Tx) error {
-		err := helper.UpdateGaon(gaonDetail)
+		err := helper.AuditChange(contextValues, utilities.AuditGaonEdit, utilities.EntityGaon, gaonDetail.ID, tx, func() error {
+			return helper.UpdateGaon(gaonDetail, tx)
+		})
 		if err != nil {
 			utilities.HandlerError(w, http.StatusBadRequest, "EditGaon: Failed to update gaon:", err)
 			return err
 		}
 
-		err = helper.EditUser(gaonDetail.LekhPalName, gaonDetail.LekhPalPhone, gaonDetail.LekhPalID, tx)
-		return err
+		return helper.AuditChange(contextValues, utilities.AuditUserEdit, utilities.EntityUser, gaonDetail.LekhPalID, tx, func() error {
+			return helper.EditUser(gaonDetail.LekhPalName, gaonDetail.LekhPalPhone, gaonDetail.LekhPalID, tx)
+		})
 	})
 	if txErr != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "AddGramPanchayatInformation: AddGramPanchayat:", txErr)
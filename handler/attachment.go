@@ -0,0 +1,330 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/storage"
+	"grampanchayat/utilities"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	maxAttachmentSize = 10 << 20
+	// room for the multipart boundaries and the other form fields around the file
+	maxAttachmentRequestSize = maxAttachmentSize + 1<<20
+)
+
+// allowedAttachmentTypes are the scans and photos officers upload, checked against the sniffed content not the header
+var allowedAttachmentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+}
+
+// Attachments stores uploaded documents, selected from configuration at startup
+var Attachments storage.FileStore
+
+func UploadDeathAttachment(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "UploadDeathAttachment: cannot get death id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "UploadDeathAttachment: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	saveAttachment(w, r, models.Attachment{DeathID: deathID}, contextValues)
+}
+
+func UploadTaskAttachment(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "UploadTaskAttachment: cannot get task id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "UploadTaskAttachment: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !canActOnTask(w, taskID, contextValues) {
+		return
+	}
+
+	deathID, err := helper.GetTaskDeathID(taskID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "UploadTaskAttachment: cannot get task", err)
+		return
+	}
+
+	saveAttachment(w, r, models.Attachment{
+		DeathID: deathID,
+		TaskID:  sql.NullInt64{Int64: int64(taskID), Valid: true},
+	}, contextValues)
+}
+
+// saveAttachment reads the "file" field of the multipart form, checks its size and type, stores it and then records it
+func saveAttachment(w http.ResponseWriter, r *http.Request, attachment models.Attachment, contextValues models.ContextValues) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentRequestSize)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utilities.HandlerError(w, http.StatusRequestEntityTooLarge, "file cannot be larger than 10 MB", err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusBadRequest, "file is missing from the form", err)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxAttachmentSize {
+		utilities.HandlerError(w, http.StatusRequestEntityTooLarge, "file cannot be larger than 10 MB", errors.New("attachment too large"))
+		return
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		utilities.HandlerError(w, http.StatusBadRequest, "cannot read the file", err)
+		return
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !allowedAttachmentTypes[contentType] {
+		utilities.HandlerError(w, http.StatusUnsupportedMediaType, "only pdf, jpeg and png files can be attached", fmt.Errorf("content type %s", contentType))
+		return
+	}
+
+	attachment.FileName = filepath.Base(header.Filename)
+	attachment.ContentType = contentType
+	attachment.SizeBytes = header.Size
+	attachment.StorageKey = uuid.New().String()
+	attachment.UploadedBy = contextValues.ID
+	if documentType := r.FormValue("documentType"); documentType != "" {
+		attachment.DocumentType = sql.NullString{String: documentType, Valid: true}
+	}
+
+	err = Attachments.Save(attachment.StorageKey, io.MultiReader(bytes.NewReader(head), file))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "saveAttachment: cannot store file", err)
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		attachment.ID, err = helper.AddAttachment(attachment, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AuditCreate(contextValues, utilities.AuditAttachmentAdd, utilities.EntityAttachment, attachment.ID, tx)
+	})
+	if err != nil {
+		// nothing refers to the stored file anymore
+		if deleteErr := Attachments.Delete(attachment.StorageKey); deleteErr != nil {
+			logrus.Printf("saveAttachment: cannot remove stored file:%v", deleteErr)
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "saveAttachment: cannot add attachment", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = utilities.Encoder(w, attachment)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "saveAttachment: EncoderError", err)
+		return
+	}
+}
+
+// GetDeathAttachments lists the attachments of the death and of all its tasks
+func GetDeathAttachments(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetDeathAttachments: cannot get death id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathAttachments: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	attachments, err := helper.GetAttachments(deathID, 0)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathAttachments: cannot get attachments", err)
+		return
+	}
+
+	err = utilities.Encoder(w, attachments)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathAttachments: EncoderError", err)
+		return
+	}
+}
+
+func GetTaskAttachments(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetTaskAttachments: cannot get task id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskAttachments: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	deathID, err := helper.GetTaskDeathID(taskID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "task not found", err)
+		return
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskAttachments: cannot get task", err)
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	attachments, err := helper.GetAttachments(deathID, taskID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskAttachments: cannot get attachments", err)
+		return
+	}
+
+	err = utilities.Encoder(w, attachments)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskAttachments: EncoderError", err)
+		return
+	}
+}
+
+// getAccessibleAttachment writes the error response itself when the attachment is missing or its death is not posted to the caller
+func getAccessibleAttachment(w http.ResponseWriter, r *http.Request, contextValues models.ContextValues) (models.Attachment, bool) {
+	attachmentID, err := strconv.Atoi(chi.URLParam(r, "attachmentID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "getAccessibleAttachment: cannot get attachment id", err)
+		return models.Attachment{}, false
+	}
+
+	attachment, err := helper.GetAttachment(attachmentID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "attachment not found", err)
+		return attachment, false
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "getAccessibleAttachment: cannot get attachment", err)
+		return attachment, false
+	}
+
+	return attachment, canAccessDeath(w, attachment.DeathID, contextValues)
+}
+
+func DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DownloadAttachment: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	attachment, ok := getAccessibleAttachment(w, r, contextValues)
+	if !ok {
+		return
+	}
+
+	file, err := Attachments.Open(attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		utilities.HandlerError(w, http.StatusNotFound, "attachment file is missing", err)
+		return
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DownloadAttachment: cannot open file", err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.FileName))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		logrus.Printf("DownloadAttachment: cannot write file:%v", err)
+	}
+}
+
+// DeleteAttachment archives an attachment, officials can delete their own uploads and attachment:delete allows any.
+// The stored file is kept, so the archived record in the audit trail still points at the document.
+func DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteAttachment: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	attachment, ok := getAccessibleAttachment(w, r, contextValues)
+	if !ok {
+		return
+	}
+
+	if attachment.UploadedBy != contextValues.ID {
+		canDelete, err := helper.HasPermission(contextValues.Role, utilities.PermissionAttachmentDelete)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusInternalServerError, "DeleteAttachment: cannot check permission", err)
+			return
+		}
+		if !canDelete {
+			utilities.HandlerError(w, http.StatusForbidden, "only the uploader can delete this attachment", errors.New("attachment uploaded by someone else"))
+			return
+		}
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditAttachmentDelete, utilities.EntityAttachment, attachment.ID, tx, func() error {
+			return helper.ArchiveAttachment(attachment.ID, tx)
+		})
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteAttachment: cannot delete attachment", err)
+		return
+	}
+
+	message := "successfully deleted attachment"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteAttachment: EncoderError", err)
+		return
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func auditLogFilters(r *http.Request) (models.AuditLogFilter, error) {
+	filter := models.AuditLogFilter{
+		Action: r.URL.Query().Get("action"),
+		Entity: r.URL.Query().Get("entity"),
+		Limit:  defaultLimit,
+	}
+
+	var err error
+	if actorID := r.URL.Query().Get("actorId"); actorID != "" {
+		filter.ActorID, err = strconv.Atoi(actorID)
+		if err != nil {
+			logrus.Printf("auditLogFilters: cannot get actor id:%v", err)
+			return filter, err
+		}
+	}
+
+	if entityID := r.URL.Query().Get("entityId"); entityID != "" {
+		filter.EntityID, err = strconv.Atoi(entityID)
+		if err != nil {
+			logrus.Printf("auditLogFilters: cannot get entity id:%v", err)
+			return filter, err
+		}
+	}
+
+	if fromDate := r.URL.Query().Get("fromDate"); fromDate != "" {
+		filter.FromDate, err = time.Parse("02-01-2006", fromDate)
+		if err != nil {
+			logrus.Printf("auditLogFilters: cannot get from date:%v", err)
+			return filter, err
+		}
+	}
+
+	if toDate := r.URL.Query().Get("toDate"); toDate != "" {
+		filter.ToDate, err = time.Parse("02-01-2006", toDate)
+		if err != nil {
+			logrus.Printf("auditLogFilters: cannot get to date:%v", err)
+			return filter, err
+		}
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			logrus.Printf("Limit: cannot get limit:%v", err)
+			return filter, err
+		}
+	}
+
+	if page := r.URL.Query().Get("page"); page != "" {
+		filter.Page, err = strconv.Atoi(page)
+		if err != nil {
+			logrus.Printf("Page: cannot get page:%v", err)
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// GetAuditLogs lists audit entries, newest first, filtered by actor, action, entity and date
+func GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditLogFilters(r)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "cannot get audit log filters properly", err)
+		return
+	}
+
+	auditLogs, err := helper.GetAuditLogs(filter)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetAuditLogs: cannot get audit logs", err)
+		return
+	}
+
+	err = utilities.Encoder(w, auditLogs)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetAuditLogs: EncoderError", err)
+		return
+	}
+}
+
+// VerifyAuditLog recomputes the hash chain and reports the first entry that was tampered with
+func VerifyAuditLog(w http.ResponseWriter, _ *http.Request) {
+	status, err := helper.VerifyAuditChain()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "VerifyAuditLog: cannot verify audit log", err)
+		return
+	}
+
+	err = utilities.Encoder(w, status)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "VerifyAuditLog: EncoderError", err)
+		return
+	}
+}
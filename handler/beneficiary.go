@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// validateBeneficiary tidies up the details as typed in and returns what is wrong with them for the user
+func validateBeneficiary(beneficiary *models.Beneficiary) error {
+	beneficiary.Name = strings.TrimSpace(beneficiary.Name)
+	beneficiary.Relation = strings.ToLower(strings.TrimSpace(beneficiary.Relation))
+	beneficiary.PhoneNo = strings.TrimSpace(beneficiary.PhoneNo)
+	beneficiary.AadharNumber = strings.ReplaceAll(strings.TrimSpace(beneficiary.AadharNumber), " ", "")
+	beneficiary.BankAccountNumber = strings.TrimSpace(beneficiary.BankAccountNumber)
+	beneficiary.IFSCCode = strings.ToUpper(strings.TrimSpace(beneficiary.IFSCCode))
+
+	switch {
+	case beneficiary.Name == "":
+		return errors.New("beneficiary name cannot be empty")
+	case !isBeneficiaryRelation(beneficiary.Relation):
+		return errors.New("relation must be one of " + strings.Join(utilities.BeneficiaryRelations, ", "))
+	case !utilities.IsValidPhone(beneficiary.PhoneNo):
+		return errors.New("phone number must be a 10 digit mobile number")
+	case !utilities.IsValidAadhaar(beneficiary.AadharNumber):
+		return errors.New("aadhar number is not valid")
+	case !utilities.IsValidBankAccount(beneficiary.BankAccountNumber):
+		return errors.New("bank account number must have 9 to 18 digits")
+	case !utilities.IsValidIFSC(beneficiary.IFSCCode):
+		return errors.New("ifsc code is not valid")
+	}
+	return nil
+}
+
+func isBeneficiaryRelation(relation string) bool {
+	for _, known := range utilities.BeneficiaryRelations {
+		if relation == known {
+			return true
+		}
+	}
+	return false
+}
+
+// canViewBeneficiaries lets the Sachiv, the dashboards and the departments owning a task of the death see the nominees.
+// It writes the error response itself.
+func canViewBeneficiaries(w http.ResponseWriter, deathID int, contextValues models.ContextValues) bool {
+	for _, permission := range []string{utilities.PermissionBeneficiaryEdit, utilities.PermissionDashboardView} {
+		hasPermission, err := helper.HasPermission(contextValues.Role, permission)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusInternalServerError, "canViewBeneficiaries: cannot check permission", err)
+			return false
+		}
+		if hasPermission {
+			return true
+		}
+	}
+
+	actionableTaskTypes, err := helper.GetActionableTaskTypes(contextValues.Role)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "canViewBeneficiaries: cannot get actionable task types", err)
+		return false
+	}
+
+	hasTask, err := helper.HasTaskOnDeath(deathID, actionableTaskTypes)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "canViewBeneficiaries: cannot check tasks", err)
+		return false
+	}
+	if !hasTask {
+		utilities.HandlerError(w, http.StatusForbidden, "death has no task of your department", errors.New("no owned task on death"))
+		return false
+	}
+	return true
+}
+
+func GetBeneficiaries(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetBeneficiaries: cannot get death id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetBeneficiaries: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) || !canViewBeneficiaries(w, deathID, contextValues) {
+		return
+	}
+
+	beneficiaries, err := helper.GetBeneficiaries(deathID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetBeneficiaries: cannot get beneficiaries", err)
+		return
+	}
+
+	err = utilities.Encoder(w, beneficiaries)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetBeneficiaries: EncoderError", err)
+		return
+	}
+}
+
+func AddBeneficiary(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddBeneficiary: cannot get death id", err)
+		return
+	}
+
+	var beneficiary models.Beneficiary
+	err = utilities.Decoder(r, &beneficiary)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddBeneficiary: Decoder error:", err)
+		return
+	}
+
+	err = validateBeneficiary(&beneficiary)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	beneficiary.DeathID = deathID
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddBeneficiary: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return addBeneficiary(beneficiary, contextValues, tx)
+	})
+	if err != nil {
+		if errors.Is(err, helper.ErrDuplicateBeneficiary) {
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddBeneficiary: cannot add beneficiary", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	message := "successfully added beneficiary"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddBeneficiary: EncoderError", err)
+		return
+	}
+}
+
+func addBeneficiary(beneficiary models.Beneficiary, contextValues models.ContextValues, tx *sqlx.Tx) error {
+	beneficiaryID, err := helper.AddBeneficiary(beneficiary, contextValues.ID, tx)
+	if err != nil {
+		return err
+	}
+	return helper.AuditCreate(contextValues, utilities.AuditBeneficiaryAdd, utilities.EntityBeneficiary, beneficiaryID, tx)
+}
+
+// getBeneficiaryDeath writes the error response itself when the beneficiary is missing or its death is not posted to the caller
+func getBeneficiaryDeath(w http.ResponseWriter, beneficiaryID int, contextValues models.ContextValues) bool {
+	deathID, err := helper.GetBeneficiaryDeathID(beneficiaryID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "beneficiary not found", err)
+		return false
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "getBeneficiaryDeath: cannot get beneficiary", err)
+		return false
+	}
+	return canAccessDeath(w, deathID, contextValues)
+}
+
+func EditBeneficiary(w http.ResponseWriter, r *http.Request) {
+	beneficiaryID, err := strconv.Atoi(chi.URLParam(r, "beneficiaryID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "EditBeneficiary: cannot get beneficiary id", err)
+		return
+	}
+
+	var beneficiary models.Beneficiary
+	err = utilities.Decoder(r, &beneficiary)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "EditBeneficiary: Decoder error:", err)
+		return
+	}
+
+	err = validateBeneficiary(&beneficiary)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	beneficiary.ID = beneficiaryID
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditBeneficiary: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !getBeneficiaryDeath(w, beneficiaryID, contextValues) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditBeneficiaryEdit, utilities.EntityBeneficiary, beneficiaryID, tx, func() error {
+			return helper.UpdateBeneficiary(beneficiary, tx)
+		})
+	})
+	if err != nil {
+		if errors.Is(err, helper.ErrDuplicateBeneficiary) {
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditBeneficiary: cannot update beneficiary", err)
+		return
+	}
+
+	message := "successfully updated beneficiary"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditBeneficiary: EncoderError", err)
+		return
+	}
+}
+
+func DeleteBeneficiary(w http.ResponseWriter, r *http.Request) {
+	beneficiaryID, err := strconv.Atoi(chi.URLParam(r, "beneficiaryID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "DeleteBeneficiary: cannot get beneficiary id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteBeneficiary: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !getBeneficiaryDeath(w, beneficiaryID, contextValues) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditBeneficiaryDelete, utilities.EntityBeneficiary, beneficiaryID, tx, func() error {
+			return helper.ArchiveBeneficiary(beneficiaryID, tx)
+		})
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteBeneficiary: cannot delete beneficiary", err)
+		return
+	}
+
+	message := "successfully deleted beneficiary"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteBeneficiary: EncoderError", err)
+		return
+	}
+}
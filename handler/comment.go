@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxCommentLength = 2000
+
+func AddDeathComment(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddDeathComment: cannot get death id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddDeathComment: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	addComment(w, r, models.Comment{DeathID: deathID}, contextValues)
+}
+
+func AddTaskComment(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddTaskComment: cannot get task id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddTaskComment: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	deathID, err := helper.GetTaskDeathID(taskID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "task not found", err)
+		return
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddTaskComment: cannot get task", err)
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	addComment(w, r, models.Comment{
+		DeathID: deathID,
+		TaskID:  sql.NullInt64{Int64: int64(taskID), Valid: true},
+	}, contextValues)
+}
+
+func addComment(w http.ResponseWriter, r *http.Request, comment models.Comment, contextValues models.ContextValues) {
+	var request models.CommentRequest
+	err := utilities.Decoder(r, &request)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "addComment: Decoder error:", err)
+		return
+	}
+
+	comment.Body = strings.TrimSpace(request.Body)
+	if comment.Body == "" {
+		utilities.HandlerError(w, http.StatusBadRequest, "comment cannot be empty", errors.New("empty comment"))
+		return
+	}
+	if utf8.RuneCountInString(comment.Body) > maxCommentLength {
+		utilities.HandlerError(w, http.StatusBadRequest, "comment cannot be longer than 2000 characters", errors.New("comment too long"))
+		return
+	}
+	comment.AuthorID = contextValues.ID
+	comment.AuthorRole = contextValues.Role
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		commentID, err := helper.AddComment(comment, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AuditCreate(contextValues, utilities.AuditCommentAdd, utilities.EntityComment, commentID, tx)
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "addComment: cannot add comment", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	message := "successfully added comment"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "addComment: EncoderError", err)
+		return
+	}
+}
+
+// GetDeathComments returns the whole conversation on the death, the comments on its tasks included
+func GetDeathComments(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetDeathComments: cannot get death id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathComments: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	comments, err := helper.GetComments(deathID, 0)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathComments: cannot get comments", err)
+		return
+	}
+
+	err = utilities.Encoder(w, comments)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathComments: EncoderError", err)
+		return
+	}
+}
+
+func GetTaskComments(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetTaskComments: cannot get task id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskComments: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	deathID, err := helper.GetTaskDeathID(taskID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "task not found", err)
+		return
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskComments: cannot get task", err)
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	comments, err := helper.GetComments(deathID, taskID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskComments: cannot get comments", err)
+		return
+	}
+
+	err = utilities.Encoder(w, comments)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskComments: EncoderError", err)
+		return
+	}
+}
+
+// setDeathComments fills the comment thread of every death in the list with one query
+func setDeathComments(deaths []models.DeathDetailsOutput) error {
+	deathIDs := make([]int, 0, len(deaths))
+	for i := range deaths {
+		deathIDs = append(deathIDs, deaths[i].ID)
+	}
+
+	threads, err := helper.GetDeathsComments(deathIDs)
+	if err != nil {
+		return err
+	}
+	for i := range deaths {
+		deaths[i].Comments = threads[deaths[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validateDeathEdit tidies up the corrected details and returns what is wrong with them for the user
+func validateDeathEdit(edit *models.DeathEditRequest) error {
+	edit.Name = strings.TrimSpace(edit.Name)
+	edit.PhoneNo = strings.TrimSpace(edit.PhoneNo)
+	edit.Gender = strings.ToLower(strings.TrimSpace(edit.Gender))
+	edit.AadharNumber = strings.ReplaceAll(strings.TrimSpace(edit.AadharNumber), " ", "")
+	edit.Address = strings.TrimSpace(edit.Address)
+	edit.Occupation = strings.TrimSpace(edit.Occupation)
+
+	switch {
+	case edit.Name == "":
+		return errors.New("name cannot be empty")
+	case edit.Age < 0:
+		return errors.New("age cannot be negative")
+	case edit.Gender != "male" && edit.Gender != "female" && edit.Gender != "other":
+		return errors.New("gender must be male, female or other")
+	case edit.PhoneNo != "" && !utilities.IsValidPhone(edit.PhoneNo):
+		return errors.New("phone number must be a 10 digit mobile number")
+	case edit.AadharNumber != "" && !utilities.IsValidAadhaar(edit.AadharNumber):
+		return errors.New("aadhar number is not valid")
+	case edit.DateOfDeath.IsZero() || edit.DateOfDeath.After(time.Now()):
+		return errors.New("date of death cannot be empty or in the future")
+	case edit.GaonID == 0:
+		return errors.New("gaon cannot be empty")
+	}
+	return nil
+}
+
+// EditDeath lets the user who registered the death correct it
+func EditDeath(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "EditDeath: cannot get death id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditDeath: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	createdBy, err := helper.GetDeathCreatedBy(deathID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "death not found", err)
+		return
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditDeath: cannot get death", err)
+		return
+	}
+	if createdBy != contextValues.ID {
+		utilities.HandlerError(w, http.StatusForbidden, "only the user who registered the death can edit it", errors.New("not the registrant"))
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	editDeath(w, r, deathID, contextValues)
+}
+
+// EditDeathAdmin lets an admin correct any death of their jurisdiction after it was submitted
+func EditDeathAdmin(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "EditDeathAdmin: cannot get death id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditDeathAdmin: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !isDeathInJurisdiction(w, r, deathID) {
+		return
+	}
+
+	editDeath(w, r, deathID, contextValues)
+}
+
+func editDeath(w http.ResponseWriter, r *http.Request, deathID int, contextValues models.ContextValues) {
+	var edit models.DeathEditRequest
+	err := utilities.Decoder(r, &edit)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "editDeath: Decoder error:", err)
+		return
+	}
+
+	err = validateDeathEdit(&edit)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if !validateDeathCodes(w, &edit.CauseOfDeath, &edit.PlaceOfDeath, &edit.Circumstance) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditDeathEdit, utilities.EntityDeath, deathID, tx, func() error {
+			return helper.UpdateDeath(deathID, contextValues.ID, edit, tx)
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, helper.ErrGaonOutsideGramPanchayat):
+			utilities.HandlerError(w, http.StatusBadRequest, err.Error(), err)
+		case errors.Is(err, sql.ErrNoRows):
+			utilities.HandlerError(w, http.StatusNotFound, "death not found", err)
+		default:
+			utilities.HandlerError(w, http.StatusInternalServerError, "editDeath: cannot update death", err)
+		}
+		return
+	}
+
+	message := "successfully updated death"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "editDeath: EncoderError", err)
+		return
+	}
+}
+
+func GetDeathVersions(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetDeathVersions: cannot get death id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathVersions: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !canAccessDeath(w, deathID, contextValues) {
+		return
+	}
+
+	getDeathVersions(w, deathID)
+}
+
+// GetDeathVersionsAdmin shows reviewers what was changed on the death after it was registered
+func GetDeathVersionsAdmin(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetDeathVersionsAdmin: cannot get death id", err)
+		return
+	}
+
+	if !isDeathInJurisdiction(w, r, deathID) {
+		return
+	}
+
+	getDeathVersions(w, deathID)
+}
+
+func getDeathVersions(w http.ResponseWriter, deathID int) {
+	versions, err := helper.GetDeathVersions(deathID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "getDeathVersions: cannot get death versions", err)
+		return
+	}
+
+	err = utilities.Encoder(w, versions)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "getDeathVersions: EncoderError", err)
+		return
+	}
+}
+
+// ArchiveDeath takes a duplicate or fraudulent death out of the listings and counts, the reason is kept on it
+func ArchiveDeath(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "ArchiveDeath: cannot get death id", err)
+		return
+	}
+
+	var request models.ArchiveDeathRequest
+	err = utilities.Decoder(r, &request)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "ArchiveDeath: Decoder error:", err)
+		return
+	}
+
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" {
+		utilities.HandlerError(w, http.StatusBadRequest, "reason cannot be empty", errors.New("empty archive reason"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ArchiveDeath: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !isDeathInJurisdiction(w, r, deathID) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditDeathArchive, utilities.EntityDeath, deathID, tx, func() error {
+			return helper.ArchiveDeath(deathID, contextValues.ID, request.Reason, tx)
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, helper.ErrDeathArchived):
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+		case errors.Is(err, sql.ErrNoRows):
+			utilities.HandlerError(w, http.StatusNotFound, "death not found", err)
+		default:
+			utilities.HandlerError(w, http.StatusInternalServerError, "ArchiveDeath: cannot archive death", err)
+		}
+		return
+	}
+
+	message := "successfully archived death"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ArchiveDeath: EncoderError", err)
+		return
+	}
+}
+
+func RestoreDeath(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "RestoreDeath: cannot get death id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "RestoreDeath: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !isDeathInJurisdiction(w, r, deathID) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditDeathRestore, utilities.EntityDeath, deathID, tx, func() error {
+			return helper.RestoreDeath(deathID, tx)
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, helper.ErrDeathNotArchived):
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+		case errors.Is(err, sql.ErrNoRows):
+			utilities.HandlerError(w, http.StatusNotFound, "death not found", err)
+		default:
+			utilities.HandlerError(w, http.StatusInternalServerError, "RestoreDeath: cannot restore death", err)
+		}
+		return
+	}
+
+	message := "successfully restored death"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "RestoreDeath: EncoderError", err)
+		return
+	}
+}
+
+// GetArchivedDeaths takes the death filters, only the places, search and pages apply
+func GetArchivedDeaths(w http.ResponseWriter, r *http.Request) {
+	deathFilters, err := deathFilters(r)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "cannot get death filters properly", err)
+		return
+	}
+
+	deaths, err := helper.GetArchivedDeaths(deathFilters)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetArchivedDeaths: cannot get archived deaths", err)
+		return
+	}
+
+	err = utilities.Encoder(w, deaths)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetArchivedDeaths: EncoderError", err)
+		return
+	}
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func isDeathCodeKind(kind string) bool {
+	switch kind {
+	case utilities.DeathCodeCause, utilities.DeathCodePlace, utilities.DeathCodeCircumstance:
+		return true
+	}
+	return false
+}
+
+// normaliseDeathCode turns "Road Accident" into "road_accident", the form codes are stored and sent in
+func normaliseDeathCode(code string) string {
+	return strings.Join(strings.Fields(strings.ToLower(code)), "_")
+}
+
+// validateDeathCodes checks the codes given for a death against their lists, an empty code is allowed.
+// It writes the error response itself.
+func validateDeathCodes(w http.ResponseWriter, cause, place, circumstance *string) bool {
+	for _, field := range []struct {
+		kind string
+		code *string
+	}{
+		{utilities.DeathCodeCause, cause},
+		{utilities.DeathCodePlace, place},
+		{utilities.DeathCodeCircumstance, circumstance},
+	} {
+		*field.code = normaliseDeathCode(*field.code)
+		if *field.code == "" {
+			continue
+		}
+		exists, err := helper.IsDeathCode(field.kind, *field.code)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusInternalServerError, "validateDeathCodes: cannot check death code", err)
+			return false
+		}
+		if !exists {
+			utilities.HandlerError(w, http.StatusBadRequest, *field.code+" is not on the list of "+field.kind+" of death", errors.New("unknown death code"))
+			return false
+		}
+	}
+	return true
+}
+
+// GetDeathCodes returns the lists the registration form picks the cause, place and circumstance from
+func GetDeathCodes(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && !isDeathCodeKind(kind) {
+		utilities.HandlerError(w, http.StatusBadRequest, "kind must be cause, place or circumstance", errors.New("unknown death code kind"))
+		return
+	}
+
+	codes, err := helper.GetDeathCodes(kind)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathCodes: cannot get death codes", err)
+		return
+	}
+
+	err = utilities.Encoder(w, codes)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathCodes: EncoderError", err)
+		return
+	}
+}
+
+func AddDeathCode(w http.ResponseWriter, r *http.Request) {
+	var code models.DeathCode
+	err := utilities.Decoder(r, &code)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddDeathCode: Decoder error:", err)
+		return
+	}
+
+	code.Code = normaliseDeathCode(code.Code)
+	code.Name = strings.TrimSpace(code.Name)
+	switch {
+	case !isDeathCodeKind(code.Kind):
+		utilities.HandlerError(w, http.StatusBadRequest, "kind must be cause, place or circumstance", errors.New("unknown death code kind"))
+		return
+	case code.Code == "" || code.Name == "":
+		utilities.HandlerError(w, http.StatusBadRequest, "code and name cannot be empty", errors.New("empty death code"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddDeathCode: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		codeID, err := helper.AddDeathCode(code, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AuditCreate(contextValues, utilities.AuditDeathCodeAdd, utilities.EntityDeathCode, codeID, tx)
+	})
+	if err != nil {
+		if errors.Is(err, helper.ErrDuplicateDeathCode) {
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddDeathCode: cannot add death code", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	message := "successfully added death code"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddDeathCode: EncoderError", err)
+		return
+	}
+}
+
+// EditDeathCode renames a code, the code itself cannot change as deaths are already registered with it
+func EditDeathCode(w http.ResponseWriter, r *http.Request) {
+	codeID, err := strconv.Atoi(chi.URLParam(r, "codeID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "EditDeathCode: cannot get code id", err)
+		return
+	}
+
+	var code models.DeathCode
+	err = utilities.Decoder(r, &code)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "EditDeathCode: Decoder error:", err)
+		return
+	}
+
+	code.ID = codeID
+	code.Name = strings.TrimSpace(code.Name)
+	if code.Name == "" {
+		utilities.HandlerError(w, http.StatusBadRequest, "name cannot be empty", errors.New("empty death code name"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditDeathCode: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditDeathCodeEdit, utilities.EntityDeathCode, codeID, tx, func() error {
+			return helper.UpdateDeathCode(code, tx)
+		})
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditDeathCode: cannot update death code", err)
+		return
+	}
+
+	message := "successfully updated death code"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditDeathCode: EncoderError", err)
+		return
+	}
+}
+
+// DeleteDeathCode takes the code off its list, deaths registered with it keep it
+func DeleteDeathCode(w http.ResponseWriter, r *http.Request) {
+	codeID, err := strconv.Atoi(chi.URLParam(r, "codeID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "DeleteDeathCode: cannot get code id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteDeathCode: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditDeathCodeDelete, utilities.EntityDeathCode, codeID, tx, func() error {
+			return helper.ArchiveDeathCode(codeID, tx)
+		})
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteDeathCode: cannot delete death code", err)
+		return
+	}
+
+	message := "successfully deleted death code"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteDeathCode: EncoderError", err)
+		return
+	}
+}
+
+// GetDeathBreakdown counts the deaths by cause, place and circumstance for the dashboards, it takes the death filters
+func GetDeathBreakdown(w http.ResponseWriter, r *http.Request) {
+	deathFilters, err := deathFilters(r)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "cannot get death filters properly", err)
+		return
+	}
+
+	breakdown, err := helper.GetDeathBreakdown(deathFilters)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathBreakdown: cannot get death breakdown", err)
+		return
+	}
+
+	err = utilities.Encoder(w, breakdown)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathBreakdown: EncoderError", err)
+		return
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/eligibility"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+)
+
+func GetEligibilityRules(w http.ResponseWriter, r *http.Request) {
+	taskTypeID, err := strconv.Atoi(chi.URLParam(r, "taskTypeID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetEligibilityRules: cannot get task type id", err)
+		return
+	}
+
+	rules, err := helper.GetEligibilityRules(taskTypeID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetEligibilityRules: cannot get eligibility rules", err)
+		return
+	}
+
+	err = utilities.Encoder(w, rules)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetEligibilityRules: EncoderError", err)
+		return
+	}
+}
+
+// SetEligibilityRules replaces the rules a death has to meet for a task of the type to be created, no rules means every death
+func SetEligibilityRules(w http.ResponseWriter, r *http.Request) {
+	taskTypeID, err := strconv.Atoi(chi.URLParam(r, "taskTypeID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "SetEligibilityRules: cannot get task type id", err)
+		return
+	}
+
+	var request models.EligibilityRules
+	err = utilities.Decoder(r, &request)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "SetEligibilityRules: Decoder error:", err)
+		return
+	}
+
+	for _, rule := range request.Rules {
+		err = eligibility.Validate(rule)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetEligibilityRules: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditTaskTypeRulesSet, utilities.EntityTaskTypeRules, taskTypeID, tx, func() error {
+			return helper.SetEligibilityRules(taskTypeID, request.Rules, tx)
+		})
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetEligibilityRules: cannot set eligibility rules", err)
+		return
+	}
+
+	message := "successfully set eligibility rules"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetEligibilityRules: EncoderError", err)
+		return
+	}
+}
+
+// GetDeathEligibility shows which task types were created for the death at registration and why
+func GetDeathEligibility(w http.ResponseWriter, r *http.Request) {
+	deathID, err := strconv.Atoi(chi.URLParam(r, "deathID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetDeathEligibility: cannot get death id", err)
+		return
+	}
+
+	if !isDeathInJurisdiction(w, r, deathID) {
+		return
+	}
+
+	decisions, err := helper.GetDeathEligibility(deathID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathEligibility: cannot get eligibility", err)
+		return
+	}
+
+	err = utilities.Encoder(w, decisions)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeathEligibility: EncoderError", err)
+		return
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetHolidays lists the days off working day slas skip
+func GetHolidays(w http.ResponseWriter, _ *http.Request) {
+	holidays, err := helper.GetHolidays()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetHolidays: cannot get holidays", err)
+		return
+	}
+
+	err = utilities.Encoder(w, holidays)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetHolidays: EncoderError", err)
+		return
+	}
+}
+
+func AddHoliday(w http.ResponseWriter, r *http.Request) {
+	var request models.HolidayRequest
+	err := utilities.Decoder(r, &request)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddHoliday: Decoder error:", err)
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", request.Date)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "date must be yyyy-mm-dd", err)
+		return
+	}
+	holiday := models.Holiday{Date: date, Name: strings.TrimSpace(request.Name)}
+	if holiday.Name == "" {
+		utilities.HandlerError(w, http.StatusBadRequest, "name cannot be empty", errors.New("empty holiday name"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddHoliday: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		holidayID, err := helper.AddHoliday(holiday, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AuditCreate(contextValues, utilities.AuditHolidayAdd, utilities.EntityHoliday, holidayID, tx)
+	})
+	if err != nil {
+		if errors.Is(err, helper.ErrDuplicateHoliday) {
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddHoliday: cannot add holiday", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	message := "successfully added holiday"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddHoliday: EncoderError", err)
+		return
+	}
+}
+
+// DeleteHoliday makes the day count again for slas still running, due dates already past stay escalated
+func DeleteHoliday(w http.ResponseWriter, r *http.Request) {
+	holidayID, err := strconv.Atoi(chi.URLParam(r, "holidayID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "DeleteHoliday: cannot get holiday id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteHoliday: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditHolidayDelete, utilities.EntityHoliday, holidayID, tx, func() error {
+			return helper.ArchiveHoliday(holidayID, tx)
+		})
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteHoliday: cannot delete holiday", err)
+		return
+	}
+
+	message := "successfully deleted holiday"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DeleteHoliday: EncoderError", err)
+		return
+	}
+}
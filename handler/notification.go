@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+)
+
+// GetNotifications lists the notifications of the caller, newest first, ?unread=true leaves out the read ones
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetNotifications: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	notifications, err := helper.GetNotifications(contextValues.ID, r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetNotifications: cannot get notifications", err)
+		return
+	}
+
+	err = utilities.Encoder(w, notifications)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetNotifications: EncoderError", err)
+		return
+	}
+}
+
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := strconv.Atoi(chi.URLParam(r, "notificationID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "MarkNotificationRead: cannot get notification id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "MarkNotificationRead: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	found, err := helper.MarkNotificationRead(notificationID, contextValues.ID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "MarkNotificationRead: cannot mark notification read", err)
+		return
+	}
+	if !found {
+		utilities.HandlerError(w, http.StatusNotFound, "notification not found", errors.New("notification not found"))
+		return
+	}
+
+	message := "successfully marked notification read"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "MarkNotificationRead: EncoderError", err)
+		return
+	}
+}
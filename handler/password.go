@@ -0,0 +1,488 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	minPasswordLength   = 8
+	maxPasswordAttempts = 5
+	passwordLockout     = 15 * time.Minute
+	passwordResetTTL    = 24 * time.Hour
+	totpIssuer          = "GramPanchayat"
+	invalidLoginMessage = "invalid phone number or password"
+)
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return errors.New("password must be at least 8 characters long")
+	}
+	if len(password) > 72 {
+		// bcrypt ignores everything after 72 bytes
+		return errors.New("password cannot be longer than 72 characters")
+	}
+	return nil
+}
+
+// AdminLogin logs administrators in with their password and totp code, without depending on sms delivery
+func AdminLogin(w http.ResponseWriter, r *http.Request) {
+	var credentials models.AdminLoginCredentials
+
+	decoderErr := utilities.Decoder(r, &credentials)
+	if decoderErr != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AdminLogin: Decoder error:", decoderErr)
+		return
+	}
+
+	if credentials.Phone == "" || credentials.Password == "" {
+		utilities.HandlerError(w, http.StatusBadRequest, "phone number and password cannot be empty", errors.New("AdminLogin: empty credentials"))
+		return
+	}
+
+	details, err := helper.FetchAdminAuthDetails(credentials.Phone)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utilities.HandlerError(w, http.StatusUnauthorized, invalidLoginMessage, err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "AdminLogin: FetchAdminAuthDetails:", err)
+		return
+	}
+
+	if details.LockedUntil.Valid && details.LockedUntil.Time.After(time.Now()) {
+		utilities.HandlerError(w, http.StatusTooManyRequests, "too many failed attempts, please try again later", errors.New("AdminLogin: account is locked"))
+		return
+	}
+
+	if !details.PasswordHash.Valid || bcrypt.CompareHashAndPassword([]byte(details.PasswordHash.String), []byte(credentials.Password)) != nil {
+		err = helper.RecordFailedPasswordAttempt(details.ID, maxPasswordAttempts, passwordLockout)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusInternalServerError, "AdminLogin: RecordFailedPasswordAttempt:", err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusUnauthorized, invalidLoginMessage, errors.New("wrong password"))
+		return
+	}
+
+	allowed, err := helper.HasPermission(details.Role, utilities.PermissionPasswordLogin)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AdminLogin: cannot check permission:", err)
+		return
+	}
+	if !allowed {
+		utilities.HandlerError(w, http.StatusForbidden, "password login is not enabled for this role", errors.New("role "+details.Role+" cannot log in with password"))
+		return
+	}
+
+	if details.TOTPEnabled {
+		if credentials.TOTPCode == "" {
+			utilities.HandlerError(w, http.StatusUnauthorized, "totp code required", errors.New("AdminLogin: totp code missing"))
+			return
+		}
+		if !totp.Validate(credentials.TOTPCode, details.TOTPSecret.String) {
+			err = helper.RecordFailedPasswordAttempt(details.ID, maxPasswordAttempts, passwordLockout)
+			if err != nil {
+				utilities.HandlerError(w, http.StatusInternalServerError, "AdminLogin: RecordFailedPasswordAttempt:", err)
+				return
+			}
+			utilities.HandlerError(w, http.StatusUnauthorized, "invalid totp code", errors.New("wrong totp code"))
+			return
+		}
+	}
+
+	err = helper.ResetFailedPasswordAttempts(details.ID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AdminLogin: ResetFailedPasswordAttempts:", err)
+		return
+	}
+
+	refreshSecret, refreshSecretHash, err := newSecretToken()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AdminLogin: cannot create refresh token:", err)
+		return
+	}
+
+	sessionID, err := helper.CreateSession(details.ID, refreshSecretHash, time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AdminLogin: CreateSession:", err)
+		return
+	}
+
+	writeTokens(w, models.UserCredentials{ID: details.ID, Role: details.Role}, sessionID, refreshSecret)
+}
+
+// ChangePassword sets the caller's password, the current one is required once a password exists and so is a totp code
+// once totp is on. Wrong ones count towards the login lockout.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var passwordChange models.PasswordChangeRequest
+
+	decoderErr := utilities.Decoder(r, &passwordChange)
+	if decoderErr != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "ChangePassword: Decoder error:", decoderErr)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ChangePassword: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	err := validatePassword(passwordChange.NewPassword)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	details, err := helper.FetchAdminAuthDetailsByID(contextValues.ID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ChangePassword: FetchAdminAuthDetailsByID:", err)
+		return
+	}
+
+	// a stolen session should not be enough to take the account over, so the same lockout as AdminLogin applies
+	if details.LockedUntil.Valid && details.LockedUntil.Time.After(time.Now()) {
+		utilities.HandlerError(w, http.StatusTooManyRequests, "too many failed attempts, please try again later", errors.New("ChangePassword: account is locked"))
+		return
+	}
+
+	if details.PasswordHash.Valid && bcrypt.CompareHashAndPassword([]byte(details.PasswordHash.String), []byte(passwordChange.CurrentPassword)) != nil {
+		err = helper.RecordFailedPasswordAttempt(details.ID, maxPasswordAttempts, passwordLockout)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusInternalServerError, "ChangePassword: RecordFailedPasswordAttempt:", err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusBadRequest, "current password is wrong", errors.New("wrong current password"))
+		return
+	}
+
+	if details.TOTPEnabled && !totp.Validate(passwordChange.TOTPCode, details.TOTPSecret.String) {
+		err = helper.RecordFailedPasswordAttempt(details.ID, maxPasswordAttempts, passwordLockout)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusInternalServerError, "ChangePassword: RecordFailedPasswordAttempt:", err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusBadRequest, "invalid totp code", errors.New("wrong totp code"))
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(passwordChange.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ChangePassword: cannot hash password:", err)
+		return
+	}
+
+	txErr := database.Tx(func(tx *sqlx.Tx) error {
+		err := helper.UpdatePassword(contextValues.ID, string(passwordHash), tx)
+		if err != nil {
+			return err
+		}
+		return helper.AddAuditLog(contextValues, utilities.AuditPasswordChange, utilities.EntityUser, contextValues.ID, nil, nil, tx)
+	})
+	if txErr != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ChangePassword: cannot update password:", txErr)
+		return
+	}
+
+	message := "successfully changed password"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ChangePassword: EncoderError", err)
+		return
+	}
+}
+
+// IssuePasswordReset creates a one time reset token for an official, the admin hands it over out of band
+func IssuePasswordReset(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "IssuePasswordReset: cannot get user id", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "IssuePasswordReset: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	details, err := helper.FetchAdminAuthDetailsByID(userID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "user not found", err)
+		return
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "IssuePasswordReset: FetchAdminAuthDetailsByID:", err)
+		return
+	}
+
+	// district wide officials are above every jurisdiction, nobody else can take over their account
+	isDistrict, err := helper.HasPermission(details.Role, utilities.PermissionScopeDistrict)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "IssuePasswordReset: cannot check permission:", err)
+		return
+	}
+	if isDistrict {
+		utilities.HandlerError(w, http.StatusForbidden, "cannot issue a password reset for a district wide official", errors.New("district wide target"))
+		return
+	}
+
+	jurisdiction, err := helper.GetJurisdiction(contextValues.ID, contextValues.Role)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "IssuePasswordReset: cannot get jurisdiction:", err)
+		return
+	}
+
+	inJurisdiction, err := helper.IsUserInJurisdiction(userID, jurisdiction)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "IssuePasswordReset: cannot check jurisdiction:", err)
+		return
+	}
+	if !inJurisdiction {
+		utilities.HandlerError(w, http.StatusForbidden, "user is outside your jurisdiction", errors.New("user outside jurisdiction"))
+		return
+	}
+
+	token, tokenHash, err := newSecretToken()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "IssuePasswordReset: cannot create reset token:", err)
+		return
+	}
+
+	expiresAt := time.Now().Add(passwordResetTTL)
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		err := helper.CreatePasswordReset(userID, tokenHash, expiresAt, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AddAuditLog(contextValues, utilities.AuditPasswordResetIssue, utilities.EntityUser, userID, nil, nil, tx)
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "IssuePasswordReset: cannot save reset token:", err)
+		return
+	}
+
+	userOutboundData := make(map[string]interface{})
+	userOutboundData["resetToken"] = token
+	userOutboundData["expiresAt"] = expiresAt.Unix()
+
+	err = utilities.Encoder(w, userOutboundData)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "IssuePasswordReset: EncoderError", err)
+		return
+	}
+}
+
+// ResetPassword sets a new password with a reset token and logs the official out everywhere
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var passwordReset models.PasswordResetRequest
+
+	decoderErr := utilities.Decoder(r, &passwordReset)
+	if decoderErr != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "ResetPassword: Decoder error:", decoderErr)
+		return
+	}
+
+	err := validatePassword(passwordReset.NewPassword)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(passwordReset.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ResetPassword: cannot hash password:", err)
+		return
+	}
+
+	var userID int
+	txErr := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		userID, err = helper.ConsumePasswordReset(hashSecretToken(passwordReset.Token), tx)
+		if err != nil {
+			return err
+		}
+		err = helper.UpdatePassword(userID, string(passwordHash), tx)
+		if err != nil {
+			return err
+		}
+
+		_, err = helper.ArchiveUserSessions(userID, tx)
+		if err != nil {
+			return err
+		}
+
+		// whoever holds the reset token acts as the official it was issued for
+		return helper.AddAuditLog(models.ContextValues{ID: userID}, utilities.AuditPasswordReset, utilities.EntityUser, userID, nil, nil, tx)
+	})
+	if txErr != nil {
+		if txErr == sql.ErrNoRows {
+			utilities.HandlerError(w, http.StatusBadRequest, "invalid or expired reset token", txErr)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "ResetPassword: cannot reset password:", txErr)
+		return
+	}
+
+	message := "successfully reset password"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ResetPassword: EncoderError", err)
+		return
+	}
+}
+
+// SetupTOTP generates a new totp secret for the caller, it stays pending until EnableTOTP confirms a code from it.
+// While totp is on it has to be disabled with a code first, so a stolen session cannot swap the second factor.
+func SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetupTOTP: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	details, err := helper.FetchAdminAuthDetailsByID(contextValues.ID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetupTOTP: FetchAdminAuthDetailsByID:", err)
+		return
+	}
+	if details.TOTPEnabled {
+		utilities.HandlerError(w, http.StatusConflict, "totp is already enabled, disable it first", errors.New("SetupTOTP: totp enabled"))
+		return
+	}
+
+	info, err := helper.GetAdminInfo(contextValues.ID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetupTOTP: cannot get user info:", err)
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: info.PhoneNumber,
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetupTOTP: cannot generate totp secret:", err)
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		err := helper.SetPendingTOTPSecret(contextValues.ID, key.Secret(), tx)
+		if err != nil {
+			return err
+		}
+		return helper.AddAuditLog(contextValues, utilities.AuditTOTPSetup, utilities.EntityUser, contextValues.ID, nil, nil, tx)
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetupTOTP: cannot save totp secret:", err)
+		return
+	}
+
+	err = utilities.Encoder(w, models.TOTPSetup{Secret: key.Secret(), URL: key.URL()})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetupTOTP: EncoderError", err)
+		return
+	}
+}
+
+// EnableTOTP turns the second factor on with the pending secret, once the authenticator shows a valid code for it
+func EnableTOTP(w http.ResponseWriter, r *http.Request) {
+	var code models.TOTPCode
+
+	decoderErr := utilities.Decoder(r, &code)
+	if decoderErr != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "EnableTOTP: Decoder error:", decoderErr)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EnableTOTP: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	details, err := helper.FetchAdminAuthDetailsByID(contextValues.ID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EnableTOTP: FetchAdminAuthDetailsByID:", err)
+		return
+	}
+
+	if details.TOTPEnabled || !details.TOTPPendingSecret.Valid {
+		utilities.HandlerError(w, http.StatusBadRequest, "totp is not set up or already enabled", errors.New("EnableTOTP: nothing to enable"))
+		return
+	}
+
+	if !totp.Validate(code.Code, details.TOTPPendingSecret.String) {
+		utilities.HandlerError(w, http.StatusBadRequest, "invalid totp code", errors.New("wrong totp code"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		err := helper.EnablePendingTOTPSecret(contextValues.ID, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AddAuditLog(contextValues, utilities.AuditTOTPEnable, utilities.EntityUser, contextValues.ID, nil, nil, tx)
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EnableTOTP: cannot enable totp:", err)
+		return
+	}
+}
+
+// DisableTOTP turns the second factor off, it needs a valid code from the secret in use
+func DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var code models.TOTPCode
+
+	decoderErr := utilities.Decoder(r, &code)
+	if decoderErr != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "DisableTOTP: Decoder error:", decoderErr)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DisableTOTP: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	details, err := helper.FetchAdminAuthDetailsByID(contextValues.ID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DisableTOTP: FetchAdminAuthDetailsByID:", err)
+		return
+	}
+
+	if !details.TOTPEnabled || !details.TOTPSecret.Valid {
+		utilities.HandlerError(w, http.StatusBadRequest, "totp is not enabled", errors.New("DisableTOTP: nothing to disable"))
+		return
+	}
+
+	if !totp.Validate(code.Code, details.TOTPSecret.String) {
+		utilities.HandlerError(w, http.StatusBadRequest, "invalid totp code", errors.New("wrong totp code"))
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		err := helper.DisableTOTP(contextValues.ID, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AddAuditLog(contextValues, utilities.AuditTOTPDisable, utilities.EntityUser, contextValues.ID, nil, nil, tx)
+	})
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "DisableTOTP: cannot disable totp:", err)
+		return
+	}
+}
@@ -0,0 +1,360 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validateSanction checks the amount, order number and date of a sanction, it writes the error response itself
+func validateSanction(w http.ResponseWriter, sanction *models.SanctionRequest) bool {
+	sanction.SanctionOrderNo = strings.TrimSpace(sanction.SanctionOrderNo)
+	switch {
+	case sanction.SanctionedPaise <= 0:
+		utilities.HandlerError(w, http.StatusBadRequest, "sanctioned amount must be more than zero", errors.New("invalid sanctioned amount"))
+		return false
+	case sanction.SanctionOrderNo == "":
+		utilities.HandlerError(w, http.StatusBadRequest, "sanction order number cannot be empty", errors.New("empty sanction order number"))
+		return false
+	case sanction.SanctionedOn.IsZero() || sanction.SanctionedOn.After(time.Now()):
+		utilities.HandlerError(w, http.StatusBadRequest, "sanction date cannot be empty or in the future", errors.New("invalid sanction date"))
+		return false
+	}
+	return true
+}
+
+// AddSanction records the amount sanctioned for a task of a monetary type to one of the beneficiaries of the death
+func AddSanction(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddSanction: cannot get task id", err)
+		return
+	}
+
+	var sanction models.SanctionRequest
+	err = utilities.Decoder(r, &sanction)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddSanction: Decoder error:", err)
+		return
+	}
+
+	if !validateSanction(w, &sanction) {
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddSanction: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !isTaskInJurisdiction(w, r, taskID) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		sanctionID, err := helper.AddSanction(taskID, sanction, contextValues.ID, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AuditCreate(contextValues, utilities.AuditSanctionAdd, utilities.EntitySanction, sanctionID, tx)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, helper.ErrBeneficiaryNotOnDeath), errors.Is(err, helper.ErrTaskNotMonetary):
+			utilities.HandlerError(w, http.StatusBadRequest, err.Error(), err)
+		case errors.Is(err, helper.ErrDuplicateSanction):
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+		default:
+			utilities.HandlerError(w, http.StatusInternalServerError, "AddSanction: cannot add sanction", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	message := "successfully added sanction"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddSanction: EncoderError", err)
+		return
+	}
+}
+
+// sanctionInJurisdiction finds the task of a live sanction and checks it against the caller, it writes the error response itself
+func sanctionInJurisdiction(w http.ResponseWriter, r *http.Request, sanctionID int) bool {
+	taskID, err := helper.GetSanctionTaskID(sanctionID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "sanction not found", err)
+		return false
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "sanctionInJurisdiction: cannot get sanction", err)
+		return false
+	}
+	return isTaskInJurisdiction(w, r, taskID)
+}
+
+// EditSanction corrects a sanction entered wrong, the beneficiary cannot change and the amount cannot go below what is paid
+func EditSanction(w http.ResponseWriter, r *http.Request) {
+	sanctionID, err := strconv.Atoi(chi.URLParam(r, "sanctionID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "EditSanction: cannot get sanction id", err)
+		return
+	}
+
+	var sanction models.SanctionRequest
+	err = utilities.Decoder(r, &sanction)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "EditSanction: Decoder error:", err)
+		return
+	}
+
+	if !validateSanction(w, &sanction) {
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditSanction: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !sanctionInJurisdiction(w, r, sanctionID) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditSanctionEdit, utilities.EntitySanction, sanctionID, tx, func() error {
+			return helper.EditSanction(sanctionID, sanction, tx)
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, helper.ErrSanctionBelowDisbursed):
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+		case errors.Is(err, sql.ErrNoRows):
+			utilities.HandlerError(w, http.StatusNotFound, "sanction not found", err)
+		default:
+			utilities.HandlerError(w, http.StatusInternalServerError, "EditSanction: cannot update sanction", err)
+		}
+		return
+	}
+
+	message := "successfully updated sanction"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "EditSanction: EncoderError", err)
+		return
+	}
+}
+
+// ArchiveSanction takes a sanction recorded by mistake off the ledger with the reason, it stays in the audit log
+func ArchiveSanction(w http.ResponseWriter, r *http.Request) {
+	sanctionID, err := strconv.Atoi(chi.URLParam(r, "sanctionID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "ArchiveSanction: cannot get sanction id", err)
+		return
+	}
+
+	var request models.ArchiveSanctionRequest
+	err = utilities.Decoder(r, &request)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "ArchiveSanction: Decoder error:", err)
+		return
+	}
+
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" {
+		utilities.HandlerError(w, http.StatusBadRequest, "reason cannot be empty", errors.New("empty archive reason"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ArchiveSanction: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !sanctionInJurisdiction(w, r, sanctionID) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditSanctionArchive, utilities.EntitySanction, sanctionID, tx, func() error {
+			return helper.ArchiveSanction(sanctionID, contextValues.ID, request.Reason, tx)
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, helper.ErrSanctionHasPayouts):
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+		case errors.Is(err, sql.ErrNoRows):
+			utilities.HandlerError(w, http.StatusNotFound, "sanction not found", err)
+		default:
+			utilities.HandlerError(w, http.StatusInternalServerError, "ArchiveSanction: cannot archive sanction", err)
+		}
+		return
+	}
+
+	message := "successfully archived sanction"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ArchiveSanction: EncoderError", err)
+		return
+	}
+}
+
+// AddPayout records an instalment paid against a sanction, together they can never be more than the sanction
+func AddPayout(w http.ResponseWriter, r *http.Request) {
+	sanctionID, err := strconv.Atoi(chi.URLParam(r, "sanctionID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddPayout: cannot get sanction id", err)
+		return
+	}
+
+	var payout models.PayoutRequest
+	err = utilities.Decoder(r, &payout)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AddPayout: Decoder error:", err)
+		return
+	}
+
+	payout.PaymentReference = strings.TrimSpace(payout.PaymentReference)
+	switch {
+	case payout.AmountPaise <= 0:
+		utilities.HandlerError(w, http.StatusBadRequest, "paid amount must be more than zero", errors.New("invalid paid amount"))
+		return
+	case payout.PaymentReference == "":
+		utilities.HandlerError(w, http.StatusBadRequest, "payment reference cannot be empty", errors.New("empty payment reference"))
+		return
+	case payout.PaidOn.IsZero() || payout.PaidOn.After(time.Now()):
+		utilities.HandlerError(w, http.StatusBadRequest, "payment date cannot be empty or in the future", errors.New("invalid payment date"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddPayout: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !sanctionInJurisdiction(w, r, sanctionID) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		payoutID, err := helper.AddPayout(sanctionID, payout, contextValues.ID, tx)
+		if err != nil {
+			return err
+		}
+		return helper.AuditCreate(contextValues, utilities.AuditPayoutAdd, utilities.EntityPayout, payoutID, tx)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, helper.ErrPayoutExceedsSanction), errors.Is(err, helper.ErrDuplicatePaymentReference):
+			utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+		default:
+			utilities.HandlerError(w, http.StatusInternalServerError, "AddPayout: cannot add payout", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	message := "successfully added payout"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AddPayout: EncoderError", err)
+		return
+	}
+}
+
+func GetTaskLedger(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetTaskLedger: cannot get task id", err)
+		return
+	}
+
+	if !isTaskInJurisdiction(w, r, taskID) {
+		return
+	}
+
+	sanctions, err := helper.GetTaskLedger(taskID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskLedger: cannot get ledger", err)
+		return
+	}
+
+	err = utilities.Encoder(w, sanctions)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskLedger: EncoderError", err)
+		return
+	}
+}
+
+func payoutFilters(r *http.Request) (models.PayoutFilter, error) {
+	var filter models.PayoutFilter
+
+	var err error
+	if fromDate := r.URL.Query().Get("fromDate"); fromDate != "" {
+		filter.FromDate, err = time.Parse("02-01-2006", fromDate)
+		if err != nil {
+			logrus.Printf("payoutFilters: cannot get from date:%v", err)
+			return filter, err
+		}
+	}
+
+	if toDate := r.URL.Query().Get("toDate"); toDate != "" {
+		filter.ToDate, err = time.Parse("02-01-2006", toDate)
+		if err != nil {
+			logrus.Printf("payoutFilters: cannot get to date:%v", err)
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// GetPayoutTotals reports sanctioned, disbursed and pending amounts per tehsil of the caller's jurisdiction and for the district
+func GetPayoutTotals(w http.ResponseWriter, r *http.Request) {
+	filter, err := payoutFilters(r)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "cannot get payout filters properly", err)
+		return
+	}
+
+	jurisdiction, err := callerJurisdiction(r)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetPayoutTotals: cannot get jurisdiction", err)
+		return
+	}
+
+	tehsils, err := helper.GetPayoutTotals(filter, jurisdiction)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetPayoutTotals: cannot get payout totals", err)
+		return
+	}
+
+	report := models.PayoutReport{Tehsils: tehsils}
+	for _, tehsil := range tehsils {
+		report.District.Sanctions += tehsil.Sanctions
+		report.District.SanctionedPaise += tehsil.SanctionedPaise
+		report.District.DisbursedPaise += tehsil.DisbursedPaise
+		report.District.PendingPaise += tehsil.PendingPaise
+	}
+
+	err = utilities.Encoder(w, report)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetPayoutTotals: EncoderError", err)
+		return
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"grampanchayat/database"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strings"
+)
+
+func GetPermissions(w http.ResponseWriter, _ *http.Request) {
+	permissions, err := helper.GetPermissions()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetPermissions: cannot get permissions", err)
+		return
+	}
+
+	err = utilities.Encoder(w, permissions)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetPermissions: EncoderError", err)
+		return
+	}
+}
+
+func SetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	var rolePermissions models.RolePermissions
+
+	decoderErr := utilities.Decoder(r, &rolePermissions)
+	if decoderErr != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "SetRolePermissions: Decoder error:", decoderErr)
+		return
+	}
+
+	if rolePermissions.RoleID == 0 {
+		utilities.HandlerError(w, http.StatusBadRequest, "role id cannot be empty", errors.New("SetRolePermissions: role id cannot be empty"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetRolePermissions: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	unknown, err := helper.GetUnknownPermissions(rolePermissions.Permissions)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetRolePermissions: cannot check permissions", err)
+		return
+	}
+	if len(unknown) > 0 {
+		utilities.HandlerError(w, http.StatusBadRequest, "unknown permissions: "+strings.Join(unknown, ", "), errors.New("SetRolePermissions: unknown permissions"))
+		return
+	}
+
+	txErr := database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditRolePermissionsSet, utilities.EntityRolePermissions, rolePermissions.RoleID, tx, func() error {
+			return helper.SetRolePermissions(rolePermissions.RoleID, rolePermissions.Permissions, contextValues.Role, tx)
+		})
+	})
+	if txErr != nil {
+		if errors.Is(txErr, helper.ErrPermissionManageOwnRole) || errors.Is(txErr, helper.ErrPermissionManageLastRole) {
+			utilities.HandlerError(w, http.StatusConflict, txErr.Error(), txErr)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "SetRolePermissions: cannot set role permissions", txErr)
+		return
+	}
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"grampanchayat/database/helper"
+	"grampanchayat/models"
+	"grampanchayat/utilities"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// newSecretToken returns a random secret and the hash of it that gets stored, used for refresh and reset tokens
+func newSecretToken() (string, string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return encoded, hashSecretToken(encoded), nil
+}
+
+func hashSecretToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// refresh tokens are "<session id>.<secret>" so the session can be found without storing the secret
+func parseRefreshToken(refreshToken string) (uuid.UUID, string, error) {
+	parts := strings.SplitN(refreshToken, ".", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return uuid.Nil, "", errors.New("malformed refresh token")
+	}
+	sessionID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	return sessionID, parts[1], nil
+}
+
+func createAccessToken(userCredentials models.UserCredentials, sessionID uuid.UUID) (string, time.Time, error) {
+	expiresAt := time.Now().Add(accessTokenTTL)
+
+	claims := &models.Claims{
+		ID:        userCredentials.ID,
+		Role:      userCredentials.Role,
+		SessionID: sessionID,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expiresAt.Unix(),
+		},
+	}
+
+	tokenString, err := SigningKeys.Sign(claims)
+	return tokenString, expiresAt, err
+}
+
+// GetJWKS publishes the public signing keys for services that verify our tokens
+func GetJWKS(w http.ResponseWriter, _ *http.Request) {
+	err := utilities.Encoder(w, SigningKeys.JWKS())
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetJWKS: EncoderError", err)
+		return
+	}
+}
+
+func writeTokens(w http.ResponseWriter, userCredentials models.UserCredentials, sessionID uuid.UUID, refreshSecret string) {
+	tokenString, expiresAt, err := createAccessToken(userCredentials, sessionID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "TokenString: cannot create token string:", err)
+		return
+	}
+
+	userOutboundData := make(map[string]interface{})
+
+	userOutboundData["token"] = tokenString
+	userOutboundData["expiresAt"] = expiresAt.Unix()
+	userOutboundData["refreshToken"] = sessionID.String() + "." + refreshSecret
+
+	err = utilities.Encoder(w, userOutboundData)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "Login: EncoderError:", err)
+		return
+	}
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a rotated refresh token.
+// Presenting the refresh token it was rotated away from ends the whole session, a wrong one is only refused.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var request models.RefreshTokenRequest
+
+	decoderErr := utilities.Decoder(r, &request)
+	if decoderErr != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "RefreshToken: Decoder error:", decoderErr)
+		return
+	}
+
+	sessionID, secret, err := parseRefreshToken(request.RefreshToken)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusUnauthorized, "invalid refresh token", err)
+		return
+	}
+
+	newSecret, newSecretHash, err := newSecretToken()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "RefreshToken: cannot create refresh token:", err)
+		return
+	}
+
+	secretHash := hashSecretToken(secret)
+	userCredentials, rotated, err := helper.RotateRefreshToken(sessionID, secretHash, newSecretHash, time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "RefreshToken: cannot rotate refresh token:", err)
+		return
+	}
+
+	if !rotated {
+		// an old refresh token of the session was replayed, nobody should keep using the session.
+		// Any other secret is just wrong and leaves the session to its owner.
+		replayed, err := helper.ArchiveReplayedSession(sessionID, secretHash)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusInternalServerError, "RefreshToken: cannot end session:", err)
+			return
+		}
+		if replayed {
+			utilities.HandlerError(w, http.StatusUnauthorized, "invalid refresh token", errors.New("refresh token reused, session ended"))
+			return
+		}
+		utilities.HandlerError(w, http.StatusUnauthorized, "invalid refresh token", errors.New("wrong refresh token or session expired"))
+		return
+	}
+
+	writeTokens(w, userCredentials, sessionID, newSecret)
+}
@@ -1,16 +1,20 @@
 package handler
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
+	"github.com/thoas/go-funk"
 	"grampanchayat/database"
 	"grampanchayat/database/helper"
 	"grampanchayat/models"
 	"grampanchayat/utilities"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func DeathRegistration(w http.ResponseWriter, r *http.Request) {
@@ -28,13 +32,49 @@ func DeathRegistration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !validateDeathCodes(w, &deathDetails.CauseOfDeath, &deathDetails.PlaceOfDeath, &deathDetails.Circumstance) {
+		return
+	}
+
+	deathDetails.DuplicateJustification = strings.TrimSpace(deathDetails.DuplicateJustification)
+	if deathDetails.OverrideDuplicates && deathDetails.DuplicateJustification == "" {
+		utilities.HandlerError(w, http.StatusBadRequest, "justification is needed to register a possible duplicate", errors.New("empty duplicate justification"))
+		return
+	}
+
+	for i := range deathDetails.Beneficiaries {
+		err := validateBeneficiary(&deathDetails.Beneficiaries[i])
+		if err != nil {
+			utilities.HandlerError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
+	var candidates []models.DuplicateCandidate
+
 	// transaction started
 	txErr := database.Tx(func(tx *sqlx.Tx) error {
+		var err error
+		candidates, err = helper.FindDuplicateDeaths(deathDetails, tx)
+		if err != nil {
+			return err
+		}
+		if len(candidates) > 0 && !deathDetails.OverrideDuplicates {
+			return helper.ErrDuplicateDeath
+		}
+
 		deathID, err := helper.DeathRegistration(deathDetails, contextValues.ID, tx)
 		if err != nil {
 			return err
 		}
 
+		if len(candidates) > 0 {
+			err = helper.MarkPossibleDuplicate(deathID, candidates, deathDetails.DuplicateJustification, tx)
+			if err != nil {
+				return err
+			}
+		}
+
 		deathDetails.ID = deathID
 
 		addressId, err := helper.AddAddress(deathDetails.Address, tx)
@@ -43,9 +83,46 @@ func DeathRegistration(w http.ResponseWriter, r *http.Request) {
 		}
 
 		err = helper.AddDeathAddress(deathID, addressId, tx)
-		return err
+		if err != nil {
+			return err
+		}
+
+		err = helper.AddDeathVersion(deathID, contextValues.ID, tx)
+		if err != nil {
+			return err
+		}
+
+		err = helper.AuditCreate(contextValues, utilities.AuditDeathRegister, utilities.EntityDeath, deathID, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, beneficiary := range deathDetails.Beneficiaries {
+			beneficiary.DeathID = deathID
+			err = addBeneficiary(beneficiary, contextValues, tx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 	if txErr != nil {
+		if errors.Is(txErr, helper.ErrDuplicateDeath) {
+			// the candidates go back so the user can check them before registering again with an override
+			w.WriteHeader(http.StatusConflict)
+			err := utilities.Encoder(w, models.DuplicateDeathsResponse{
+				MsgToUser:  "death looks like one already registered, check the candidates or register again with a justification",
+				Candidates: candidates,
+			})
+			if err != nil {
+				utilities.HandlerError(w, http.StatusInternalServerError, "DeathRegistration: EncoderError", err)
+			}
+			return
+		}
+		if errors.Is(txErr, helper.ErrDuplicateBeneficiary) {
+			utilities.HandlerError(w, http.StatusConflict, txErr.Error(), txErr)
+			return
+		}
 		utilities.HandlerError(w, http.StatusInternalServerError, "DeathRegistration ", txErr)
 		return
 	}
@@ -72,6 +149,10 @@ func GetDeathsCompleted(w http.ResponseWriter, r *http.Request) {
 	GetDeaths(w, r, "completed")
 }
 
+func GetDeathsNoTasks(w http.ResponseWriter, r *http.Request) {
+	GetDeaths(w, r, utilities.DeathStatusNoTasks)
+}
+
 func GetDeaths(w http.ResponseWriter, r *http.Request, status string) {
 	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
 	if !ok {
@@ -81,12 +162,22 @@ func GetDeaths(w http.ResponseWriter, r *http.Request, status string) {
 
 	displayTaskTypes, _ := helper.GetDisplayTypes(contextValues.Role)
 	actionableTaskTypes, _ := helper.GetActionableTaskTypes(contextValues.Role)
+	canViewAllTasks, err := helper.HasPermission(contextValues.Role, utilities.PermissionTaskViewAll)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeaths: cannot check permission", err)
+		return
+	}
 	search := r.URL.Query().Get("search")
 	deathDetails, err := helper.GetDeathsNew(displayTaskTypes, status, contextValues.ID, search)
 	if err != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeaths: cannot get new deaths", err)
 		return
 	}
+	holidays, err := helper.GetHolidayCalendar()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeaths: cannot get holidays", err)
+		return
+	}
 	deathDetailsOutput := make([]models.DeathDetailsOutput, 0)
 	for i, _ := range deathDetails {
 		var out []models.TaskDetail
@@ -95,12 +186,13 @@ func GetDeaths(w http.ResponseWriter, r *http.Request, status string) {
 			utilities.HandlerError(w, http.StatusInternalServerError, "GetDeaths: UnMarshal", err)
 			return
 		}
+		setTaskFlags(out, holidays)
 		var finalTaskDetails []models.TaskDetail
 		for j, _ := range out {
 			if funk.ContainsString(actionableTaskTypes, out[j].TaskType) {
 				out[j].IsEditable = true
 				finalTaskDetails = append(finalTaskDetails, out[j])
-			} else if contextValues.Role == utilities.Sachiv || contextValues.Role == utilities.Sahayak {
+			} else if canViewAllTasks {
 				//non editable but show
 				finalTaskDetails = append(finalTaskDetails, out[j])
 			}
@@ -117,12 +209,21 @@ func GetDeaths(w http.ResponseWriter, r *http.Request, status string) {
 			CreatedBy:    deathDetails[i].CreatedBy,
 			CreatedAt:    deathDetails[i].CreatedAt,
 			DateOfDeath:  deathDetails[i].DateOfDeath,
+			CauseOfDeath: deathDetails[i].CauseOfDeath,
+			PlaceOfDeath: deathDetails[i].PlaceOfDeath,
+			Circumstance: deathDetails[i].Circumstance,
 			TaskDetails:  out,
 		}
 		deathDetailsOutput = append(deathDetailsOutput, deathDetailsOut)
 
 	}
 
+	err = setDeathComments(deathDetailsOutput)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeaths: cannot get comments", err)
+		return
+	}
+
 	err = utilities.Encoder(w, deathDetailsOutput)
 	if err != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "GetDeaths: EncoderError", err)
@@ -130,6 +231,36 @@ func GetDeaths(w http.ResponseWriter, r *http.Request, status string) {
 	}
 }
 
+// canActOnTask lets only the task's own department, posted where the death happened, change the task.
+// It writes the error response itself.
+func canActOnTask(w http.ResponseWriter, taskID int, contextValues models.ContextValues) bool {
+	actionableTaskTypes, err := helper.GetActionableTaskTypes(contextValues.Role)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "canActOnTask: cannot get actionable task types", err)
+		return false
+	}
+
+	access, err := helper.GetTaskAccess(taskID, contextValues.ID, actionableTaskTypes)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "task not found", err)
+		return false
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "canActOnTask: cannot check task access", err)
+		return false
+	}
+
+	if !access.IsActionable {
+		utilities.HandlerError(w, http.StatusForbidden, "task does not belong to your department", errors.New("task type not actionable by role"))
+		return false
+	}
+	if !access.InJurisdiction {
+		utilities.HandlerError(w, http.StatusForbidden, "death is outside your jurisdiction", errors.New("death outside jurisdiction"))
+		return false
+	}
+	return true
+}
+
 func ProcessingTask(w http.ResponseWriter, r *http.Request) {
 	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
 	if err != nil {
@@ -144,9 +275,31 @@ func ProcessingTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "MarkProcessing: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !canActOnTask(w, taskID, contextValues) {
+		return
+	}
+
 	if processing.Started {
-		err = helper.MarkProcessing(taskID)
+		err = database.Tx(func(tx *sqlx.Tx) error {
+			return helper.AuditChange(contextValues, utilities.AuditTaskProcessing, utilities.EntityTask, taskID, tx, func() error {
+				return helper.MarkProcessing(taskID, contextValues.ID, tx)
+			})
+		})
 		if err != nil {
+			if errors.Is(err, helper.ErrIllegalTaskTransition) {
+				utilities.HandlerError(w, http.StatusConflict, "task cannot be started from its current status", err)
+				return
+			}
+			if errors.Is(err, helper.ErrTaskBlocked) {
+				utilities.HandlerError(w, http.StatusConflict, err.Error(), err)
+				return
+			}
 			utilities.HandlerError(w, http.StatusInternalServerError, "MarkProcessing: cannot update task as processing", err)
 			return
 		}
@@ -157,20 +310,24 @@ func ProcessingTask(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else {
-		err := helper.TaskRejected(taskID, processing)
-		if err != nil {
-			utilities.HandlerError(w, http.StatusInternalServerError, "Failed to reject task", err)
-			return
+		// a task that is not started is closed as rejected unless the user says it does not apply
+		closure := models.TaskClosure{
+			Outcome:    processing.Outcome,
+			ReasonCode: processing.ReasonCode,
+			Reason:     processing.Reason,
 		}
-		message := "successfully rejected task"
-		err = utilities.Encoder(w, &message)
-		if err != nil {
-			utilities.HandlerError(w, http.StatusInternalServerError, "MarkProcessing: EncoderError", err)
+		if closure.Outcome == "" {
+			closure.Outcome = utilities.TaskOutcomeRejected
+		}
+		if closure.Outcome == utilities.TaskOutcomeCompleted {
+			utilities.HandlerError(w, http.StatusBadRequest, "use completed to complete a task", errors.New("completed outcome on start-processing"))
 			return
 		}
+		closeTask(w, taskID, contextValues, closure)
 	}
 }
 
+// MarkCompleted closes the task, the optional body tells the outcome and reason, by default it is simply completed
 func MarkCompleted(w http.ResponseWriter, r *http.Request) {
 	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
 	if err != nil {
@@ -178,16 +335,301 @@ func MarkCompleted(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = helper.MarkCompleted(taskID)
-	if err != nil {
-		utilities.HandlerError(w, http.StatusInternalServerError, "MarkProcessing: cannot update task as completed", err)
+	closure := models.TaskClosure{Outcome: utilities.TaskOutcomeCompleted}
+	if r.ContentLength != 0 {
+		err = utilities.Decoder(r, &closure)
+		if err != nil {
+			utilities.HandlerError(w, http.StatusBadRequest, "MarkCompleted: Decoder error:", err)
+			return
+		}
+		if closure.Outcome == "" {
+			closure.Outcome = utilities.TaskOutcomeCompleted
+		}
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "MarkCompleted: Context for details:", errors.New("cannot get context details"))
 		return
 	}
 
+	if !canActOnTask(w, taskID, contextValues) {
+		return
+	}
+
+	closeTask(w, taskID, contextValues, closure)
+}
+
+func closeTask(w http.ResponseWriter, taskID int, contextValues models.ContextValues, closure models.TaskClosure) {
+	action := utilities.AuditTaskComplete
 	message := "successfully marked task as completed"
+	switch closure.Outcome {
+	case utilities.TaskOutcomeNotApplicable:
+		action = utilities.AuditTaskNotApplicable
+		message = "successfully marked task as not applicable"
+	case utilities.TaskOutcomeRejected:
+		action = utilities.AuditTaskReject
+		message = "successfully rejected task"
+	}
+
+	err := database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, action, utilities.EntityTask, taskID, tx, func() error {
+			return helper.CloseTask(taskID, contextValues.ID, closure, tx)
+		})
+	})
+	if err != nil {
+		if errors.Is(err, helper.ErrInvalidTaskClosure) {
+			utilities.HandlerError(w, http.StatusBadRequest, "unknown outcome or reason code for this outcome", err)
+			return
+		}
+		if errors.Is(err, helper.ErrIllegalTaskTransition) {
+			utilities.HandlerError(w, http.StatusConflict, "task cannot be closed this way from its current status", err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "MarkCompleted: cannot close task", err)
+		return
+	}
+
 	err = utilities.Encoder(w, &message)
 	if err != nil {
 		utilities.HandlerError(w, http.StatusInternalServerError, "MarkCompleted: EncoderError", err)
 		return
 	}
 }
+
+// ReopenTask undoes a completion made by mistake. The task's own department can reopen it, as can roles allowed to reopen any task.
+func ReopenTask(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "ReopenTask: cannot get task id", err)
+		return
+	}
+
+	var reopen models.TaskReopenRequest
+	err = utilities.Decoder(r, &reopen)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "ReopenTask: Decoder error:", err)
+		return
+	}
+
+	if strings.TrimSpace(reopen.Reason) == "" {
+		utilities.HandlerError(w, http.StatusBadRequest, "reason cannot be empty", errors.New("ReopenTask: reason cannot be empty"))
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ReopenTask: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	canReopenAny, err := helper.HasPermission(contextValues.Role, utilities.PermissionTaskReopen)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ReopenTask: cannot check permission", err)
+		return
+	}
+
+	if canReopenAny {
+		if !isTaskInJurisdiction(w, r, taskID) {
+			return
+		}
+	} else if !canActOnTask(w, taskID, contextValues) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditTaskReopen, utilities.EntityTask, taskID, tx, func() error {
+			return helper.ReopenTask(taskID, contextValues.ID, reopen.Reason, tx)
+		})
+	})
+	if err != nil {
+		if errors.Is(err, helper.ErrIllegalTaskTransition) {
+			utilities.HandlerError(w, http.StatusConflict, "only completed tasks can be reopened", err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "ReopenTask: cannot reopen task", err)
+		return
+	}
+
+	message := "successfully reopened task"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "ReopenTask: EncoderError", err)
+		return
+	}
+}
+
+// AssignTask lets a supervisor hand a task in their jurisdiction to another official of the owning department
+func AssignTask(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AssignTask: cannot get task id", err)
+		return
+	}
+
+	var assign models.TaskAssignRequest
+	err = utilities.Decoder(r, &assign)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "AssignTask: Decoder error:", err)
+		return
+	}
+
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AssignTask: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	if !isTaskInJurisdiction(w, r, taskID) {
+		return
+	}
+
+	err = database.Tx(func(tx *sqlx.Tx) error {
+		return helper.AuditChange(contextValues, utilities.AuditTaskAssign, utilities.EntityTask, taskID, tx, func() error {
+			return helper.AssignTask(taskID, assign.UserID, tx)
+		})
+	})
+	if err != nil {
+		if errors.Is(err, helper.ErrInvalidAssignee) {
+			utilities.HandlerError(w, http.StatusBadRequest, "user cannot be assigned this task", err)
+			return
+		}
+		utilities.HandlerError(w, http.StatusInternalServerError, "AssignTask: cannot assign task", err)
+		return
+	}
+
+	message := "successfully assigned task"
+	err = utilities.Encoder(w, &message)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "AssignTask: EncoderError", err)
+		return
+	}
+}
+
+// GetMyTasks lists the tasks assigned to the caller, ?status= narrows it to new, processing or completed
+func GetMyTasks(w http.ResponseWriter, r *http.Request) {
+	contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+	if !ok {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetMyTasks: Context for details:", errors.New("cannot get context details"))
+		return
+	}
+
+	tasks, err := helper.GetMyTasks(contextValues.ID, r.URL.Query().Get("status"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetMyTasks: cannot get tasks", err)
+		return
+	}
+
+	err = utilities.Encoder(w, tasks)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetMyTasks: EncoderError", err)
+		return
+	}
+}
+
+// setTaskFlags fills the due date of tasks whose type has an sla, flags the open ones that are past it
+// and the new ones still waiting on a prerequisite task. A task waiting on a prerequisite has no sla running yet.
+func setTaskFlags(tasks []models.TaskDetail, holidays utilities.Holidays) {
+	now := time.Now()
+	for i := range tasks {
+		tasks[i].IsBlocked = tasks[i].Status == utilities.TaskStatusNew && len(tasks[i].BlockedBy) > 0
+		if tasks[i].SLADays == 0 || tasks[i].SLAStartedAt == nil {
+			continue
+		}
+		dueDate := utilities.DueDate(*tasks[i].SLAStartedAt, tasks[i].SLADays, tasks[i].SLAWorking, holidays)
+		tasks[i].DueDate = &dueDate
+		tasks[i].IsOverdue = tasks[i].Status != utilities.TaskStatusCompleted && now.After(dueDate)
+	}
+}
+
+// GetTaskOutcomeReasons lists the reason codes of every task outcome
+func GetTaskOutcomeReasons(w http.ResponseWriter, _ *http.Request) {
+	reasons, err := helper.GetTaskOutcomeReasons()
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskOutcomeReasons: cannot get reasons", err)
+		return
+	}
+
+	err = utilities.Encoder(w, reasons)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskOutcomeReasons: EncoderError", err)
+		return
+	}
+}
+
+// GetTaskStatusHistory lists every status change of a task with who made it and when
+func GetTaskStatusHistory(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+	if err != nil {
+		utilities.HandlerError(w, http.StatusBadRequest, "GetTaskStatusHistory: cannot get task id", err)
+		return
+	}
+
+	if !isTaskInJurisdiction(w, r, taskID) {
+		return
+	}
+
+	history, err := helper.GetTaskStatusHistory(taskID)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskStatusHistory: cannot get history", err)
+		return
+	}
+
+	err = utilities.Encoder(w, history)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "GetTaskStatusHistory: EncoderError", err)
+		return
+	}
+}
+
+// canAccessDeath writes the error response itself when the death is missing or not posted to the caller
+func canAccessDeath(w http.ResponseWriter, deathID int, contextValues models.ContextValues) bool {
+	inJurisdiction, err := helper.CanAccessDeath(deathID, contextValues.ID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "death not found", err)
+		return false
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "canAccessDeath: cannot check death access", err)
+		return false
+	}
+	if !inJurisdiction {
+		utilities.HandlerError(w, http.StatusForbidden, "death is outside your jurisdiction", errors.New("death outside jurisdiction"))
+		return false
+	}
+	return true
+}
+
+// isTaskInJurisdiction checks the task's death against the caller's tehsils and blocks, it writes the error response itself
+func isTaskInJurisdiction(w http.ResponseWriter, r *http.Request, taskID int) bool {
+	deathID, err := helper.GetTaskDeathID(taskID)
+	if err == sql.ErrNoRows {
+		utilities.HandlerError(w, http.StatusNotFound, "task not found", err)
+		return false
+	}
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "isTaskInJurisdiction: cannot get task", err)
+		return false
+	}
+	return isDeathInJurisdiction(w, r, deathID)
+}
+
+// isDeathInJurisdiction checks the death against the tehsils and blocks of an admin, it writes the error response itself
+func isDeathInJurisdiction(w http.ResponseWriter, r *http.Request, deathID int) bool {
+	jurisdiction, err := callerJurisdiction(r)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "isDeathInJurisdiction: cannot get jurisdiction", err)
+		return false
+	}
+
+	inJurisdiction, err := helper.IsDeathInJurisdiction(deathID, jurisdiction)
+	if err != nil {
+		utilities.HandlerError(w, http.StatusInternalServerError, "isDeathInJurisdiction: cannot check jurisdiction", err)
+		return false
+	}
+	if !inJurisdiction {
+		utilities.HandlerError(w, http.StatusForbidden, "death is outside your jurisdiction", errors.New("death outside jurisdiction"))
+		return false
+	}
+	return true
+}
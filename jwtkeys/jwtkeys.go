@@ -0,0 +1,153 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"os"
+)
+
+// KeyConfig is one entry of the JWT_KEYS environment variable.
+// HS256 keys need a secret, RS256 and EdDSA keys need a PEM private key to sign
+// or only a PEM public key when the key is kept around just to verify older tokens.
+type KeyConfig struct {
+	ID         string `json:"kid"`
+	Algorithm  string `json:"alg"`
+	Secret     string `json:"secret"`
+	PrivateKey string `json:"privateKey"`
+	PublicKey  string `json:"publicKey"`
+}
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeySet signs tokens with the active key and verifies tokens signed by any configured key
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+	order  []string
+}
+
+// LoadFromEnv reads the keys from JWT_KEYS (json array of KeyConfig) and the signing key id from JWT_ACTIVE_KEY_ID.
+// A plain JWT_SECRET is accepted as a single HS256 key for simple deployments.
+func LoadFromEnv() (*KeySet, error) {
+	var configs []KeyConfig
+	activeKeyID := os.Getenv("JWT_ACTIVE_KEY_ID")
+
+	if raw := os.Getenv("JWT_KEYS"); raw != "" {
+		err := json.Unmarshal([]byte(raw), &configs)
+		if err != nil {
+			return nil, fmt.Errorf("jwtkeys: cannot parse JWT_KEYS: %v", err)
+		}
+	} else if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		configs = append(configs, KeyConfig{ID: "default", Algorithm: jwt.SigningMethodHS256.Alg(), Secret: secret})
+		if activeKeyID == "" {
+			activeKeyID = "default"
+		}
+	} else {
+		return nil, errors.New("jwtkeys: neither JWT_KEYS nor JWT_SECRET is set")
+	}
+
+	return NewKeySet(configs, activeKeyID)
+}
+
+func NewKeySet(configs []KeyConfig, activeKeyID string) (*KeySet, error) {
+	keySet := &KeySet{keys: make(map[string]*signingKey)}
+	for i := range configs {
+		key, err := parseKey(configs[i])
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := keySet.keys[key.id]; exists {
+			return nil, fmt.Errorf("jwtkeys: duplicate kid %q", key.id)
+		}
+		keySet.keys[key.id] = key
+		keySet.order = append(keySet.order, key.id)
+	}
+
+	active, ok := keySet.keys[activeKeyID]
+	if !ok {
+		return nil, fmt.Errorf("jwtkeys: active key %q is not configured", activeKeyID)
+	}
+	if active.signKey == nil {
+		return nil, fmt.Errorf("jwtkeys: active key %q has no private key", activeKeyID)
+	}
+	keySet.active = active
+	return keySet, nil
+}
+
+func parseKey(config KeyConfig) (*signingKey, error) {
+	if config.ID == "" {
+		return nil, errors.New("jwtkeys: key without kid")
+	}
+	key := &signingKey{id: config.ID}
+
+	var err error
+	switch config.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if config.Secret == "" {
+			return nil, fmt.Errorf("jwtkeys: key %q has no secret", config.ID)
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(config.Secret)
+		key.verifyKey = key.signKey
+	case jwt.SigningMethodRS256.Alg():
+		key.method = jwt.SigningMethodRS256
+		if config.PrivateKey != "" {
+			var privateKey *rsa.PrivateKey
+			privateKey, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(config.PrivateKey))
+			if err == nil {
+				key.signKey = privateKey
+				key.verifyKey = &privateKey.PublicKey
+			}
+		} else {
+			key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM([]byte(config.PublicKey))
+		}
+	case jwt.SigningMethodEdDSA.Alg():
+		key.method = jwt.SigningMethodEdDSA
+		if config.PrivateKey != "" {
+			var privateKey crypto.PrivateKey
+			privateKey, err = jwt.ParseEdPrivateKeyFromPEM([]byte(config.PrivateKey))
+			if err == nil {
+				key.signKey = privateKey
+				key.verifyKey = privateKey.(ed25519.PrivateKey).Public()
+			}
+		} else {
+			key.verifyKey, err = jwt.ParseEdPublicKeyFromPEM([]byte(config.PublicKey))
+		}
+	default:
+		return nil, fmt.Errorf("jwtkeys: key %q has unsupported alg %q", config.ID, config.Algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwtkeys: key %q: %v", config.ID, err)
+	}
+	return key, nil
+}
+
+// Sign signs the claims with the active key and sets the kid header
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+	return token.SignedString(k.active.signKey)
+}
+
+// Keyfunc resolves the verification key from the kid header, to be passed to jwt.ParseWithClaims
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", token.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
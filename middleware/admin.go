@@ -2,29 +2,62 @@ package middleware
 
 import (
 	"errors"
+	"grampanchayat/database/helper"
 	"grampanchayat/models"
 	"grampanchayat/utilities"
 	"net/http"
+	"strings"
 )
 
-func AdminMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
-		if !ok {
-			utilities.HandlerError(w, http.StatusInternalServerError, "AdminMiddleware: Context for ID:%v", errors.New("cannot get id from context"))
-			return
-		}
+// RequirePermission lets the request through only if the caller's role has been granted the permission
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+			if !ok {
+				utilities.HandlerError(w, http.StatusInternalServerError, "RequirePermission: Context for ID:%v", errors.New("cannot get id from context"))
+				return
+			}
 
-		if contextValues.Role != "Admin" {
-			_, err := w.Write([]byte("ERROR: Role mismatch"))
+			allowed, err := helper.HasPermission(contextValues.Role, permission)
 			if err != nil {
+				utilities.HandlerError(w, http.StatusInternalServerError, "RequirePermission: cannot check permission:%v", err)
+				return
+			}
+
+			if !allowed {
+				utilities.HandlerError(w, http.StatusForbidden, "permission denied", errors.New("role "+contextValues.Role+" lacks permission "+permission))
 				return
 			}
 
-			utilities.HandlerError(w, http.StatusUnauthorized, "Role invalid", errors.New("user role is invalid"))
-			return
-		}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAnyPermission lets the request through if the caller's role has at least one of the permissions
+func RequireAnyPermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contextValues, ok := r.Context().Value(utilities.UserContextKey).(models.ContextValues)
+			if !ok {
+				utilities.HandlerError(w, http.StatusInternalServerError, "RequireAnyPermission: Context for ID:%v", errors.New("cannot get id from context"))
+				return
+			}
+
+			for _, permission := range permissions {
+				allowed, err := helper.HasPermission(contextValues.Role, permission)
+				if err != nil {
+					utilities.HandlerError(w, http.StatusInternalServerError, "RequireAnyPermission: cannot check permission:%v", err)
+					return
+				}
+				if allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			utilities.HandlerError(w, http.StatusForbidden, "permission denied", errors.New("role "+contextValues.Role+" lacks permissions "+strings.Join(permissions, ", ")))
+		})
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt"
 	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
 	"grampanchayat/database/helper"
@@ -22,9 +23,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
 		claims := &models.Claims{}
 
-		tkn, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-			return handler.JwtKey, nil
-		})
+		tkn, err := jwt.ParseWithClaims(token, claims, handler.SigningKeys.Keyfunc)
 
 		if err != nil {
 			if err == jwt.ErrSignatureInvalid {
@@ -45,13 +44,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		_, err = helper.CheckSession(claims.ID)
+		err = helper.CheckSession(claims.SessionID, claims.ID)
 		if err != nil {
-			logrus.Printf("session expired:%v", err)
+			utilities.HandlerError(w, http.StatusUnauthorized, "session expired:%v", err)
 			return
 		}
 
-		value := &models.ContextValues{ID: claims.ID, Role: claims.Role}
+		value := &models.ContextValues{ID: claims.ID, Role: claims.Role, SessionID: claims.SessionID}
 		ctx := context.WithValue(r.Context(), utilities.UserContextKey, *value)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
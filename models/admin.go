@@ -2,12 +2,58 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
 	"time"
 )
 
 type AdminLoginCredentials struct {
 	Phone    string `json:"phone" db:"phone_no"`
 	Password string `json:"password" db:"password"`
+	TOTPCode string `json:"totpCode"`
+}
+
+type AdminAuthDetails struct {
+	ID                     int            `db:"id"`
+	Role                   string         `db:"role"`
+	PasswordHash           sql.NullString `db:"password_hash"`
+	TOTPSecret             sql.NullString `db:"totp_secret"`
+	TOTPPendingSecret      sql.NullString `db:"totp_pending_secret"`
+	TOTPEnabled            bool           `db:"totp_enabled"`
+	FailedPasswordAttempts int            `db:"failed_password_attempts"`
+	LockedUntil            sql.NullTime   `db:"locked_until"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+	TOTPCode        string `json:"totpCode"`
+}
+
+type PasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+type TOTPCode struct {
+	Code string `json:"code"`
+}
+
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"`
+}
+
+type Permission struct {
+	ID          int            `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	Description string         `json:"description" db:"description"`
+	Roles       pq.StringArray `json:"roles" db:"roles"`
+}
+
+type RolePermissions struct {
+	RoleID      int      `json:"roleId"`
+	Permissions []string `json:"permissions"`
 }
 
 type TehsilAndUserID struct {
@@ -29,10 +75,13 @@ type Tehsils struct {
 }
 
 type TaskDetails struct {
-	TaskID   int    `json:"taskId" db:"task_id"`
-	TaskName string `json:"taskName" db:"task_name"`
-	RoleID   int    `json:"roleId" db:"role_id"`
-	RoleName string `json:"roleName" db:"role_name"`
+	TaskID         int           `json:"taskId" db:"task_id"`
+	TaskName       string        `json:"taskName" db:"task_name"`
+	RoleID         int           `json:"roleId" db:"role_id"`
+	RoleName       string        `json:"roleName" db:"role_name"`
+	SLADays        sql.NullInt64 `json:"slaDays" db:"sla_days"`
+	SLAWorkingDays bool          `json:"slaWorkingDays" db:"sla_working_days"`
+	IsMonetary     bool          `json:"isMonetary" db:"is_monetary"`
 }
 
 type Tasks struct {
@@ -64,6 +113,10 @@ type GraphDeatils struct {
 	Date       time.Time `json:"date" db:"date"`
 	Registered int       `json:"registered" db:"registered"`
 	Completed  int       `json:"completed" db:"completed"`
+	// tasks of the deaths registered that day, by outcome
+	TasksCompleted     int `json:"tasksCompleted" db:"tasks_completed"`
+	TasksNotApplicable int `json:"tasksNotApplicable" db:"tasks_not_applicable"`
+	TasksRejected      int `json:"tasksRejected" db:"tasks_rejected"`
 }
 
 type DeathFilter struct {
@@ -73,6 +126,10 @@ type DeathFilter struct {
 	GaonID          []int
 	TaskID          []int
 	TaskName        []string
+	Outcome         []string
+	CauseOfDeath    []string
+	PlaceOfDeath    []string
+	Circumstance    []string
 	FromDate        time.Time
 	ToDate          time.Time
 	Search          string
@@ -81,10 +138,156 @@ type DeathFilter struct {
 	IsAscending     bool
 	Limit           int
 	Page            int
+	Jurisdiction    Jurisdiction
+}
+
+type SanctionRequest struct {
+	BeneficiaryID   int       `json:"beneficiaryId"`
+	SanctionedPaise int64     `json:"sanctionedPaise"`
+	SanctionOrderNo string    `json:"sanctionOrderNo"`
+	SanctionedOn    time.Time `json:"sanctionedOn"`
+}
+
+type ArchiveSanctionRequest struct {
+	Reason string `json:"reason"`
+}
+
+type PayoutRequest struct {
+	AmountPaise      int64     `json:"amountPaise"`
+	PaidOn           time.Time `json:"paidOn"`
+	PaymentReference string    `json:"paymentReference"`
+}
+
+// Sanction is the amount sanctioned to a beneficiary for a task along with what was paid of it so far
+type Sanction struct {
+	ID              int       `json:"id" db:"id"`
+	TaskID          int       `json:"taskId" db:"task_id"`
+	BeneficiaryID   int       `json:"beneficiaryId" db:"beneficiary_id"`
+	BeneficiaryName string    `json:"beneficiaryName" db:"beneficiary_name"`
+	SanctionOrderNo string    `json:"sanctionOrderNo" db:"sanction_order_no"`
+	SanctionedOn    time.Time `json:"sanctionedOn" db:"sanctioned_on"`
+	SanctionedPaise int64     `json:"sanctionedPaise" db:"sanctioned_paise"`
+	DisbursedPaise  int64     `json:"disbursedPaise" db:"disbursed_paise"`
+	PendingPaise    int64     `json:"pendingPaise" db:"-"`
+	Payouts         []Payout  `json:"payouts" db:"-"`
+	CreatedBy       int       `json:"createdBy" db:"created_by"`
+	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
+}
+
+type Payout struct {
+	ID               int       `json:"id" db:"id"`
+	SanctionID       int       `json:"sanctionId" db:"sanction_id"`
+	AmountPaise      int64     `json:"amountPaise" db:"amount_paise"`
+	PaidOn           time.Time `json:"paidOn" db:"paid_on"`
+	PaymentReference string    `json:"paymentReference" db:"payment_reference"`
+	CreatedBy        int       `json:"createdBy" db:"created_by"`
+	CreatedAt        time.Time `json:"createdAt" db:"created_at"`
+}
+
+type PayoutTotals struct {
+	TehsilID        int    `json:"tehsilId,omitempty" db:"tehsil_id"`
+	TehsilName      string `json:"tehsilName,omitempty" db:"tehsil_name"`
+	Sanctions       int    `json:"sanctions" db:"sanctions"`
+	SanctionedPaise int64  `json:"sanctionedPaise" db:"sanctioned_paise"`
+	DisbursedPaise  int64  `json:"disbursedPaise" db:"disbursed_paise"`
+	PendingPaise    int64  `json:"pendingPaise" db:"-"`
+}
+
+// PayoutReport has the totals of every tehsil in the caller's jurisdiction and their sum for the district
+type PayoutReport struct {
+	District PayoutTotals   `json:"district"`
+	Tehsils  []PayoutTotals `json:"tehsils"`
+}
+
+type PayoutFilter struct {
+	FromDate time.Time
+	ToDate   time.Time
+}
+
+// EligibilityRule is one condition a death has to meet for a task of the type to be created
+type EligibilityRule struct {
+	ID          int            `json:"id" db:"id"`
+	TaskTypeID  int            `json:"taskTypeId" db:"task_type_id"`
+	Attribute   string         `json:"attribute" db:"attribute"`
+	Operator    string         `json:"operator" db:"operator"`
+	Values      pq.StringArray `json:"values" db:"rule_values"`
+	Description string         `json:"description" db:"description"`
+}
+
+type EligibilityRules struct {
+	Rules []EligibilityRule `json:"rules"`
+}
+
+// TaskEligibility is the decision taken for a task type when the death was registered
+type TaskEligibility struct {
+	TaskTypeID   int            `json:"taskTypeId" db:"task_type_id"`
+	TaskTypeName string         `json:"taskTypeName" db:"task_type_name"`
+	IsEligible   bool           `json:"isEligible" db:"is_eligible"`
+	Reasons      pq.StringArray `json:"reasons" db:"reasons"`
+	CreatedAt    time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// DeathCode is one entry of the cause, place or circumstance of death lists
+type DeathCode struct {
+	ID   int    `json:"id" db:"id"`
+	Kind string `json:"kind" db:"kind"`
+	Code string `json:"code" db:"code"`
+	Name string `json:"name" db:"name"`
+}
+
+type DeathCodeCount struct {
+	Code   string `json:"code" db:"code"`
+	Name   string `json:"name" db:"name"`
+	Deaths int    `json:"deaths" db:"deaths"`
+}
+
+// DeathBreakdown counts the deaths matching the dashboard filters by each code list, deaths without a code count as unknown
+type DeathBreakdown struct {
+	Causes        []DeathCodeCount `json:"causes"`
+	Places        []DeathCodeCount `json:"places"`
+	Circumstances []DeathCodeCount `json:"circumstances"`
+}
+
+// ArchivedDeath is a death taken out of the listings and counts, with who archived it and why
+type ArchivedDeath struct {
+	ID                int            `json:"id" db:"id"`
+	Name              string         `json:"name" db:"name"`
+	PhoneNo           string         `json:"phoneNo" db:"phone_no"`
+	Age               int            `json:"age" db:"age"`
+	Gender            string         `json:"gender" db:"gender"`
+	AadharNumber      string         `json:"aadharNumber" db:"aadhar_number"`
+	DateOfDeath       time.Time      `json:"dateOfDeath" db:"date_of_death"`
+	CreatedAt         time.Time      `json:"createdAt" db:"created_at"`
+	CreatedBy         sql.NullInt64  `json:"createdBy" db:"created_by"`
+	GramPanchayatID   int            `json:"gramPanchayatId" db:"gram_panchayat_id"`
+	GramPanchayatName string         `json:"gramPanchayatName" db:"gram_panchayat_name"`
+	GaonID            int            `json:"gaonId" db:"gaon_id"`
+	GaonName          string         `json:"gaonName" db:"gaon_name"`
+	ArchivedAt        time.Time      `json:"archivedAt" db:"archived_at"`
+	ArchivedBy        sql.NullInt64  `json:"archivedBy" db:"archived_by"`
+	ArchivedByName    sql.NullString `json:"archivedByName" db:"archived_by_name"`
+	ArchiveReason     string         `json:"archiveReason" db:"archive_reason"`
+}
+
+type ArchiveDeathRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Jurisdiction is the part of the district a user may see, IsDistrict means no limit
+type Jurisdiction struct {
+	IsDistrict bool
+	TehsilIDs  []int
+	BlockIDs   []int
+}
+
+type BlockOfficerCreateRequest struct {
+	Name    string `json:"name"`
+	PhoneNo string `json:"phoneNo"`
+	BlockID int    `json:"blockId"`
 }
 
 type NewUserDetails struct {
-	userID          int    `json:"userID" db:"user_id"`
+	UserID          int    `json:"userID" db:"user_id"`
 	RoleID          int    `json:"roleID" db:"role_id"`
 	Name            string `json:"name" db:"name"`
 	PhoneNo         string `json:"phoneNo" db:"phone_no"`
@@ -119,6 +322,9 @@ type RandomDeathDetails struct {
 	BlockName         string         `json:"blockName" db:"block_name"`
 	GaonId            int            `json:"gaonId" db:"gaon_id"`
 	GaonName          string         `json:"gaonName" db:"gaon_name"`
+	CauseOfDeath      string         `json:"causeOfDeath" db:"cause_of_death"`
+	PlaceOfDeath      string         `json:"placeOfDeath" db:"place_of_death"`
+	Circumstance      string         `json:"circumstance" db:"circumstance"`
 	IsReviewed        bool           `json:"isReviewed" db:"is_reviewed"`
 	Comment           sql.NullString `json:"comment" db:"comment"`
 	ReviewedBy        sql.NullInt64  `json:"reviewedBy" db:"reviewed_by"`
@@ -147,3 +353,35 @@ type GaonDetails struct {
 type TaskName struct {
 	TaskName string `json:"taskName"`
 }
+
+// AuditLog is one entry of the hash chained audit log, before and after hold the changed row as json
+type AuditLog struct {
+	ID        int             `json:"id" db:"id"`
+	ActorID   int             `json:"actorId" db:"actor_id"`
+	ActorRole string          `json:"actorRole" db:"actor_role"`
+	Action    string          `json:"action" db:"action"`
+	Entity    string          `json:"entity" db:"entity"`
+	EntityID  int             `json:"entityId" db:"entity_id"`
+	Before    json.RawMessage `json:"before" db:"before"`
+	After     json.RawMessage `json:"after" db:"after"`
+	CreatedAt time.Time       `json:"createdAt" db:"created_at"`
+	PrevHash  string          `json:"prevHash" db:"prev_hash"`
+	Hash      string          `json:"hash" db:"hash"`
+}
+
+type AuditLogFilter struct {
+	ActorID  int
+	Action   string
+	Entity   string
+	EntityID int
+	FromDate time.Time
+	ToDate   time.Time
+	Limit    int
+	Page     int
+}
+
+type AuditChainStatus struct {
+	IsValid    bool `json:"isValid"`
+	Checked    int  `json:"checked"`
+	BrokenAtID int  `json:"brokenAtId,omitempty"`
+}
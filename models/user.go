@@ -2,18 +2,13 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"time"
 )
 
-package models
-
-import (
-"database/sql"
-"github.com/golang-jwt/jwt"
-"github.com/lib/pq"
-"time"
-)
-
 type FiltersCheck struct {
 	IsSearched    bool
 	SearchedName  string
@@ -24,13 +19,15 @@ type FiltersCheck struct {
 }
 
 type ContextValues struct {
-	ID   int    `json:"id"`
-	Role string `json:"role"`
+	ID        int       `json:"id"`
+	Role      string    `json:"role"`
+	SessionID uuid.UUID `json:"sessionId"`
 }
 
 type Claims struct {
-	ID   int    `json:"id"`
-	Role string `json:"role"`
+	ID        int       `json:"id"`
+	Role      string    `json:"role"`
+	SessionID uuid.UUID `json:"sessionId"`
 	jwt.StandardClaims
 }
 
@@ -48,10 +45,26 @@ type LoginWithOTP struct {
 	OTP   string `json:"otp" db:"otp"`
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
 type SendOTP struct {
 	Phone string `json:"phoneNo" db:"phone_no"`
 }
 
+type OTPDetails struct {
+	ID             int    `db:"id"`
+	OTP            string `db:"otp"`
+	FailedAttempts int    `db:"failed_attempts"`
+}
+
+type OTPStats struct {
+	LastSentAt     sql.NullTime `db:"last_sent_at"`
+	SentLastHour   int          `db:"sent_last_hour"`
+	FailedAttempts int          `db:"failed_attempts"`
+}
+
 type GramPanchayatCreateRequest struct {
 	GramPanchayat string `json:"gramPanchayat" db:"gram_panchayat"`
 	SachivName    string `json:"name" db:"name"`
@@ -164,6 +177,9 @@ type DeathDetails struct {
 	BlockName         string    `json:"blockName" db:"block_name"`
 	GaonId            int       `json:"gaonId" db:"gaon_id"`
 	GaonName          string    `json:"gaonName" db:"gaon_name"`
+	CauseOfDeath      string    `json:"causeOfDeath" db:"cause_of_death"`
+	PlaceOfDeath      string    `json:"placeOfDeath" db:"place_of_death"`
+	Circumstance      string    `json:"circumstance" db:"circumstance"`
 }
 
 type DeathRegistrationRequest struct {
@@ -177,11 +193,222 @@ type DeathRegistrationRequest struct {
 	DateOfDeath  time.Time `json:"dateOfDeath"`
 	PanchayatID  int       `json:"gramPanchayatID" db:"gram_panchayat_id"`
 	GaonID       int       `json:"gaonId" db:"gaon_id"`
+	CauseOfDeath string    `json:"causeOfDeath"`
+	PlaceOfDeath string    `json:"placeOfDeath"`
+	Circumstance string    `json:"circumstance"`
+	Occupation   string    `json:"occupation"`
+	IsBPL        bool      `json:"isBpl"`
+	Schemes      []string  `json:"schemes"`
+	// Beneficiaries can be given at registration or added later
+	Beneficiaries []Beneficiary `json:"beneficiaries"`
+	// OverrideDuplicates registers the death even when it looks like one already registered, the justification is kept on it
+	OverrideDuplicates     bool   `json:"overrideDuplicates"`
+	DuplicateJustification string `json:"duplicateJustification"`
+}
+
+// DuplicateCandidate is a registered death the new one may be the same as, MatchedOn tells why
+type DuplicateCandidate struct {
+	ID                int       `json:"id" db:"id"`
+	Name              string    `json:"name" db:"name"`
+	AadharNumber      string    `json:"aadharNumber" db:"aadhar_number"`
+	DateOfDeath       time.Time `json:"dateOfDeath" db:"date_of_death"`
+	GaonID            int       `json:"gaonId" db:"gaon_id"`
+	GaonName          string    `json:"gaonName" db:"gaon_name"`
+	GramPanchayatName string    `json:"gramPanchayatName" db:"gram_panchayat_name"`
+	CreatedBy         int       `json:"createdBy" db:"created_by"`
+	CreatedAt         time.Time `json:"createdAt" db:"created_at"`
+	SameAadhar        bool      `json:"-" db:"same_aadhar"`
+	MatchedOn         []string  `json:"matchedOn" db:"-"`
+}
+
+type DuplicateDeathsResponse struct {
+	MsgToUser  string               `json:"messageToUser"`
+	Candidates []DuplicateCandidate `json:"candidates"`
+}
+
+// DeathEditRequest replaces the details of a registered death, the gaon can only move within its gram panchayat
+type DeathEditRequest struct {
+	Name         string    `json:"name"`
+	PhoneNo      string    `json:"phoneNo"`
+	Age          int       `json:"age"`
+	Gender       string    `json:"gender"`
+	AadharNumber string    `json:"aadharNumber"`
+	Address      string    `json:"address"`
+	DateOfDeath  time.Time `json:"dateOfDeath"`
+	GaonID       int       `json:"gaonId"`
+	CauseOfDeath string    `json:"causeOfDeath"`
+	PlaceOfDeath string    `json:"placeOfDeath"`
+	Circumstance string    `json:"circumstance"`
+	Occupation   string    `json:"occupation"`
+	IsBPL        bool      `json:"isBpl"`
+	Schemes      []string  `json:"schemes"`
+}
+
+// DeathVersion is the death as it was after an edit, Changes compares it with the version before
+type DeathVersion struct {
+	Version    int                `json:"version" db:"version"`
+	Details    json.RawMessage    `json:"details" db:"-"`
+	EditedBy   sql.NullInt64      `json:"editedBy" db:"edited_by"`
+	EditorName sql.NullString     `json:"editorName" db:"editor_name"`
+	EditedAt   time.Time          `json:"editedAt" db:"edited_at"`
+	Changes    []DeathFieldChange `json:"changes" db:"-"`
+}
+
+type DeathFieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
 }
 
 type Processing struct {
-	Started bool   `json:"started"`
-	Reason  string `json:"reason"`
+	Started    bool   `json:"started"`
+	Outcome    string `json:"outcome"`
+	ReasonCode string `json:"reasonCode"`
+	Reason     string `json:"reason"`
+}
+
+// TaskClosure is how a task ended, a reason code is needed for every outcome except completed
+type TaskClosure struct {
+	Outcome    string `json:"outcome"`
+	ReasonCode string `json:"reasonCode"`
+	Reason     string `json:"reason"`
+}
+
+type TaskAssignRequest struct {
+	UserID int `json:"userId"`
+}
+
+// OverdueTask is an open task with an sla, as read by the escalation job
+type OverdueTask struct {
+	TaskID          int           `db:"id"`
+	TaskType        string        `db:"task_type"`
+	DeathName       string        `db:"death_name"`
+	SLAStartedAt    time.Time     `db:"sla_started_at"`
+	SLADays         int           `db:"sla_days"`
+	SLAWorking      bool          `db:"sla_working_days"`
+	EscalationLevel int           `db:"escalation_level"`
+	EscalatedAt     sql.NullTime  `db:"escalated_at"`
+	AssignedTo      sql.NullInt64 `db:"user_id"`
+}
+
+type Notification struct {
+	ID        int           `json:"id" db:"id"`
+	Message   string        `json:"message" db:"message"`
+	TaskID    sql.NullInt64 `json:"taskId" db:"task_id"`
+	CreatedAt time.Time     `json:"createdAt" db:"created_at"`
+	ReadAt    sql.NullTime  `json:"readAt" db:"read_at"`
+}
+
+type Attachment struct {
+	ID           int            `json:"id" db:"id"`
+	DeathID      int            `json:"deathId" db:"death_id"`
+	TaskID       sql.NullInt64  `json:"taskId" db:"task_id"`
+	DocumentType sql.NullString `json:"documentType" db:"document_type"`
+	FileName     string         `json:"fileName" db:"file_name"`
+	ContentType  string         `json:"contentType" db:"content_type"`
+	SizeBytes    int64          `json:"sizeBytes" db:"size_bytes"`
+	StorageKey   string         `json:"-" db:"storage_key"`
+	UploadedBy   int            `json:"uploadedBy" db:"uploaded_by"`
+	CreatedAt    time.Time      `json:"createdAt" db:"created_at"`
+}
+
+type Comment struct {
+	ID         int           `json:"id" db:"id"`
+	DeathID    int           `json:"deathId" db:"death_id"`
+	TaskID     sql.NullInt64 `json:"taskId" db:"task_id"`
+	AuthorID   int           `json:"authorId" db:"author_id"`
+	AuthorName string        `json:"authorName" db:"author_name"`
+	AuthorRole string        `json:"authorRole" db:"author_role"`
+	Body       string        `json:"body" db:"body"`
+	CreatedAt  time.Time     `json:"createdAt" db:"created_at"`
+}
+
+type CommentRequest struct {
+	Body string `json:"body"`
+}
+
+// Beneficiary is a nominee of the family the compensation and other benefits go to
+type Beneficiary struct {
+	ID                int       `json:"id" db:"id"`
+	DeathID           int       `json:"deathId" db:"death_id"`
+	Name              string    `json:"name" db:"name"`
+	Relation          string    `json:"relation" db:"relation"`
+	PhoneNo           string    `json:"phoneNo" db:"phone_no"`
+	AadharNumber      string    `json:"aadharNumber" db:"aadhar_number"`
+	BankAccountNumber string    `json:"bankAccountNumber" db:"bank_account_number"`
+	IFSCCode          string    `json:"ifscCode" db:"ifsc_code"`
+	CreatedBy         int       `json:"createdBy" db:"created_by"`
+	CreatedAt         time.Time `json:"createdAt" db:"created_at"`
+}
+
+type TaskTypePrerequisites struct {
+	PrerequisiteIDs []int `json:"prerequisiteIds"`
+}
+
+type TaskTypeSLA struct {
+	SLADays     int  `json:"slaDays"`
+	WorkingDays bool `json:"workingDays"`
+}
+
+type TaskTypeMonetary struct {
+	IsMonetary bool `json:"isMonetary"`
+}
+
+type Holiday struct {
+	ID   int       `json:"id" db:"id"`
+	Date time.Time `json:"date" db:"date"`
+	Name string    `json:"name" db:"name"`
+}
+
+// HolidayRequest takes the date as yyyy-mm-dd
+type HolidayRequest struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// AssignedTask is a task in the "my tasks" list of the official it is assigned to
+type AssignedTask struct {
+	TaskID            int            `json:"taskId" db:"id"`
+	TaskType          string         `json:"taskType" db:"task_type"`
+	Status            string         `json:"status" db:"status"`
+	Outcome           sql.NullString `json:"outcome" db:"outcome"`
+	StartDate         sql.NullTime   `json:"startDate" db:"start_date"`
+	AssignedAt        sql.NullTime   `json:"assignedAt" db:"assigned_at"`
+	DeathID           int            `json:"deathId" db:"death_id"`
+	Name              string         `json:"name" db:"name"`
+	DateOfDeath       time.Time      `json:"dateOfDeath" db:"date_of_death"`
+	GramPanchayatName string         `json:"gramPanchayatName" db:"gram_panchayat_name"`
+	GaonName          string         `json:"gaonName" db:"gaon_name"`
+}
+
+type TaskReopenRequest struct {
+	Reason string `json:"reason"`
+}
+
+type TaskOutcomeReason struct {
+	ID          int    `json:"id" db:"id"`
+	Outcome     string `json:"outcome" db:"outcome"`
+	Code        string `json:"code" db:"code"`
+	Description string `json:"description" db:"description"`
+}
+
+type TaskStatusHistory struct {
+	ID         int            `json:"id" db:"id"`
+	TaskID     int            `json:"taskId" db:"task_id"`
+	FromStatus sql.NullString `json:"fromStatus" db:"from_status"`
+	ToStatus   string         `json:"toStatus" db:"to_status"`
+	Outcome    sql.NullString `json:"outcome" db:"outcome"`
+	ReasonCode sql.NullString `json:"reasonCode" db:"reason_code"`
+	ActorID    sql.NullInt64  `json:"actorId" db:"actor_id"`
+	ActorName  sql.NullString `json:"actorName" db:"actor_name"`
+	Reason     sql.NullString `json:"reason" db:"reason"`
+	CreatedAt  time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// TaskAccess tells whether the caller's department handles the task and whether its death is in their area
+type TaskAccess struct {
+	IsActionable   bool `db:"is_actionable"`
+	InJurisdiction bool `db:"in_jurisdiction"`
 }
 
 type DeathDetailsOutput struct {
@@ -206,7 +433,11 @@ type DeathDetailsOutput struct {
 	TehsilName        string         `json:"tehsilName" db:"tehsil_name"`
 	BlockId           int            `json:"blockId" db:"block_id"`
 	BlockName         string         `json:"blockName" db:"block_name"`
+	CauseOfDeath      string         `json:"causeOfDeath" db:"cause_of_death"`
+	PlaceOfDeath      string         `json:"placeOfDeath" db:"place_of_death"`
+	Circumstance      string         `json:"circumstance" db:"circumstance"`
 	TaskDetails       []TaskDetail   `json:"taskDetails" db:"task_details"`
+	Comments          []Comment      `json:"comments" db:"-"`
 	IsReviewed        bool           `json:"isReviewed" db:"is_reviewed"`
 	Comment           sql.NullString `json:"comment" db:"comment"`
 	ReviewedBy        sql.NullInt64  `json:"reviewedBy" db:"reviewed_by"`
@@ -214,14 +445,26 @@ type DeathDetailsOutput struct {
 	ReviewedAt        sql.NullTime   `json:"reviewedAt" db:"reviewed_at"`
 }
 type TaskDetail struct {
-	TaskID       string `json:"taskId" db:"task_id"`
-	Status       string `json:"status" db:"status"`
-	TaskType     string `json:"name"`
-	StartDate    string `json:"startDate" db:"start_date"`
-	CompleteDate string `json:"completeDate" db:"completed_date"`
-	IsEditable   bool   `json:"isEditable" db:"is_editable"`
-	IsRejected   bool   `json:"isRejected" db:"is_rejected"`
-	Reason       string `json:"reason"`
+	TaskID       string     `json:"taskId" db:"task_id"`
+	Status       string     `json:"status" db:"status"`
+	TaskType     string     `json:"name"`
+	StartDate    string     `json:"startDate" db:"start_date"`
+	CompleteDate string     `json:"completeDate" db:"completed_date"`
+	IsEditable   bool       `json:"isEditable" db:"is_editable"`
+	IsRejected   bool       `json:"isRejected" db:"is_rejected"`
+	Outcome      string     `json:"outcome"`
+	ReasonCode   string     `json:"reasonCode"`
+	Reason       string     `json:"reason"`
+	AssignedTo   int        `json:"assignedTo"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	SLAStartedAt *time.Time `json:"slaStartedAt"`
+	SLADays      int        `json:"slaDays"`
+	SLAWorking   bool       `json:"slaWorkingDays"`
+	DueDate      *time.Time `json:"dueDate"`
+	IsOverdue    bool       `json:"isOverdue"`
+	Escalation   int        `json:"escalationLevel"`
+	BlockedBy    []string   `json:"blockedBy"`
+	IsBlocked    bool       `json:"isBlocked"`
 }
 
 type DashBoardDetails struct {
@@ -272,4 +515,3 @@ type TehsilUserDetails struct {
 	Name       string `json:"name" db:"name"`
 	PhoneNo    string `json:"phoneNo" db:"phone_no"`
 }
-
@@ -5,6 +5,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"grampanchayat/handler"
 	"grampanchayat/middleware"
+	"grampanchayat/utilities"
 	"net/http"
 )
 
@@ -16,6 +17,8 @@ func SetupRoutes() *Server {
 	router := chi.NewRouter()
 	router.Use(middleware.CommonMiddlewares()...)
 
+	can := middleware.RequirePermission
+
 	router.Route("/health", func(r chi.Router) {
 		r.Get("/api", func(w http.ResponseWriter, r *http.Request) {
 			_, err := fmt.Fprintf(w, "Remote State Team")
@@ -28,58 +31,138 @@ func SetupRoutes() *Server {
 	router.Route("/gram-panchayat", func(gramPanchayat chi.Router) {
 		gramPanchayat.Post("/send-otp", handler.SendOTP)
 		gramPanchayat.Post("/verify-otp", handler.LoginWithOTP)
+		gramPanchayat.Post("/refresh", handler.RefreshToken)
+		gramPanchayat.Get("/.well-known/jwks.json", handler.GetJWKS)
+		gramPanchayat.Post("/admin-login", handler.AdminLogin)
+		gramPanchayat.Post("/password-reset", handler.ResetPassword)
 		gramPanchayat.Route("/user", func(user chi.Router) {
 			user.Use(middleware.AuthMiddleware)
 			//TODO send grampanchayats(id and name) under this person
 			user.Get("/info", handler.GetUserInfo)
+			user.Post("/logout", handler.Logout)
+			user.Post("/logout-all", handler.LogoutAll)
+			user.Get("/my-tasks", handler.GetMyTasks)
+			user.Get("/notifications", handler.GetNotifications)
+			user.Put("/notifications/{notificationID}/read", handler.MarkNotificationRead)
+			user.With(can(utilities.PermissionPasswordLogin)).Put("/password", handler.ChangePassword)
+			user.Route("/totp", func(totp chi.Router) {
+				totp.Use(can(utilities.PermissionPasswordLogin))
+				totp.Post("/setup", handler.SetupTOTP)
+				totp.Post("/enable", handler.EnableTOTP)
+				totp.Post("/disable", handler.DisableTOTP)
+			})
 			user.Route("/death", func(death chi.Router) {
-				death.Post("/register", handler.DeathRegistration)
-				death.Get("/new", handler.GetDeathsNew)
-				death.Get("/processing", handler.GetDeathsProcessing)
-				death.Get("/completed", handler.GetDeathsCompleted)
+				death.With(can(utilities.PermissionDeathRegister)).Post("/register", handler.DeathRegistration)
+				death.With(can(utilities.PermissionDeathView)).Get("/new", handler.GetDeathsNew)
+				death.With(can(utilities.PermissionDeathView)).Get("/processing", handler.GetDeathsProcessing)
+				death.With(can(utilities.PermissionDeathView)).Get("/completed", handler.GetDeathsCompleted)
+				death.With(can(utilities.PermissionDeathView)).Get("/no-tasks", handler.GetDeathsNoTasks)
+				death.With(can(utilities.PermissionDeathView)).Get("/outcome-reasons", handler.GetTaskOutcomeReasons)
+				death.With(middleware.RequireAnyPermission(utilities.PermissionDeathRegister, utilities.PermissionDeathView, utilities.PermissionDashboardView)).Get("/codes", handler.GetDeathCodes)
+				death.With(can(utilities.PermissionDeathRegister)).Put("/edit/{deathID}", handler.EditDeath)
+				death.With(middleware.RequireAnyPermission(utilities.PermissionDeathRegister, utilities.PermissionDeathView)).Get("/versions/{deathID}", handler.GetDeathVersions)
 				death.Route("/{taskID}", func(task chi.Router) {
-					//TODO user can deny that this task does not need to be done.
-					//Task need to be completed in case of no, but the reason also need to be stored
-					//and also we need to know that it was a no, not a general complete
-					//Also for every task store processing start date
-					task.Put("/start-processing", handler.ProcessingTask)
-					task.Put("/completed", handler.MarkCompleted)
+					task.With(can(utilities.PermissionTaskUpdate)).Put("/start-processing", handler.ProcessingTask)
+					task.With(can(utilities.PermissionTaskUpdate)).Put("/completed", handler.MarkCompleted)
+					task.With(middleware.RequireAnyPermission(utilities.PermissionTaskUpdate, utilities.PermissionTaskReopen)).Put("/reopen", handler.ReopenTask)
 				})
 			})
 
+			user.Route("/attachment", func(attachment chi.Router) {
+				upload := middleware.RequireAnyPermission(utilities.PermissionDeathRegister, utilities.PermissionTaskUpdate)
+				view := middleware.RequireAnyPermission(utilities.PermissionDeathView, utilities.PermissionDashboardView)
+				attachment.With(upload).Post("/death/{deathID}", handler.UploadDeathAttachment)
+				attachment.With(can(utilities.PermissionTaskUpdate)).Post("/task/{taskID}", handler.UploadTaskAttachment)
+				attachment.With(view).Get("/death/{deathID}", handler.GetDeathAttachments)
+				attachment.With(view).Get("/task/{taskID}", handler.GetTaskAttachments)
+				attachment.With(view).Get("/{attachmentID}", handler.DownloadAttachment)
+				attachment.With(middleware.RequireAnyPermission(utilities.PermissionDeathRegister, utilities.PermissionTaskUpdate, utilities.PermissionAttachmentDelete)).Delete("/{attachmentID}", handler.DeleteAttachment)
+			})
+
+			user.Route("/comment", func(comment chi.Router) {
+				comment.Use(middleware.RequireAnyPermission(utilities.PermissionDeathView, utilities.PermissionDashboardView))
+				comment.Post("/death/{deathID}", handler.AddDeathComment)
+				comment.Post("/task/{taskID}", handler.AddTaskComment)
+				comment.Get("/death/{deathID}", handler.GetDeathComments)
+				comment.Get("/task/{taskID}", handler.GetTaskComments)
+			})
+
+			user.Route("/beneficiary", func(beneficiary chi.Router) {
+				edit := can(utilities.PermissionBeneficiaryEdit)
+				beneficiary.With(middleware.RequireAnyPermission(utilities.PermissionDeathView, utilities.PermissionDashboardView)).Get("/death/{deathID}", handler.GetBeneficiaries)
+				beneficiary.With(edit).Post("/death/{deathID}", handler.AddBeneficiary)
+				beneficiary.With(edit).Put("/{beneficiaryID}", handler.EditBeneficiary)
+				beneficiary.With(edit).Delete("/{beneficiaryID}", handler.DeleteBeneficiary)
+			})
+
 			user.Route("/admin", func(admin chi.Router) {
-				admin.Use(middleware.AdminMiddleware)
 				//admin.Get("/", handler.GetDeathDetails)
-				admin.Get("/graph", handler.GetGraph)
-				admin.Get("/info", handler.GetAdminInfo)
-				admin.Post("/role", handler.AddRole)
-				admin.Get("/role", handler.AddRole)
-				admin.Post("/bulk-role", handler.BulkAddRole)
-
-				admin.Get("/tehsils", handler.GetTehsils)
-				admin.Get("/all-tehsil", handler.GetTehsilList)
-				admin.Post("/tehsil", handler.AddSdm)
-				admin.Get("/tasks", handler.GetTasks)
-
-				admin.Post("/gram-panchayat-information", handler.AddGramPanchayatInformation)
-				admin.Get("/gram-panchayat-information", handler.GetGramPanchayatInformation)
-
-				admin.Get("/district-post", handler.GetDistrictPostInfo)
-				admin.Get("/total-deaths", handler.GetTotalDeaths)
-
-				admin.Get("/deaths", handler.GetDeathDetailsAdmin)
-
-				admin.Put("/edit-gram-panchayat", handler.EditGramPanchayat)
-				admin.Put("/edit-tehsil", handler.EditTehsil)
-				admin.Post("/block", handler.AddBlock)
-				admin.Put("/block", handler.EditBlock)
-				admin.Get("/block", handler.GetBlock)
-				admin.Get("/death-review", handler.FetchDeathReview)
-				admin.Put("/death-review", handler.ReviewDeathDetails)
-
-				admin.Post("/gaon", handler.AddGaon)
-				admin.Get("/gaon", handler.GetGaon)
-				admin.Put("/gaon", handler.EditGaon)
+				admin.With(can(utilities.PermissionDashboardView)).Get("/graph", handler.GetGraph)
+				admin.With(can(utilities.PermissionDashboardView)).Get("/death-breakdown", handler.GetDeathBreakdown)
+				admin.With(can(utilities.PermissionDashboardView)).Get("/info", handler.GetAdminInfo)
+				admin.With(can(utilities.PermissionRoleManage)).Post("/role", handler.AddRole)
+				admin.With(can(utilities.PermissionRoleManage)).Get("/role", handler.AddRole)
+				admin.With(can(utilities.PermissionRoleManage)).Post("/bulk-role", handler.BulkAddRole)
+
+				admin.With(can(utilities.PermissionPermissionManage)).Get("/permissions", handler.GetPermissions)
+				admin.With(can(utilities.PermissionPermissionManage)).Put("/role-permissions", handler.SetRolePermissions)
+
+				admin.With(can(utilities.PermissionLocationView)).Get("/tehsils", handler.GetTehsils)
+				admin.With(can(utilities.PermissionLocationView)).Get("/all-tehsil", handler.GetTehsilList)
+				admin.With(can(utilities.PermissionLocationManage)).Post("/tehsil", handler.AddSdm)
+				admin.With(can(utilities.PermissionTaskTypeView)).Get("/tasks", handler.GetTasks)
+				admin.With(can(utilities.PermissionTaskTypeManage)).Put("/task-type/{taskTypeID}/sla", handler.SetTaskTypeSLA)
+				admin.With(can(utilities.PermissionTaskTypeManage)).Put("/task-type/{taskTypeID}/prerequisites", handler.SetTaskTypePrerequisites)
+				admin.With(can(utilities.PermissionTaskTypeManage)).Put("/task-type/{taskTypeID}/monetary", handler.SetTaskTypeMonetary)
+				admin.With(can(utilities.PermissionTaskTypeView)).Get("/task-type/{taskTypeID}/eligibility-rules", handler.GetEligibilityRules)
+				admin.With(can(utilities.PermissionTaskTypeManage)).Put("/task-type/{taskTypeID}/eligibility-rules", handler.SetEligibilityRules)
+				admin.With(can(utilities.PermissionTaskTypeView)).Get("/holidays", handler.GetHolidays)
+				admin.With(can(utilities.PermissionTaskTypeManage)).Post("/holiday", handler.AddHoliday)
+				admin.With(can(utilities.PermissionTaskTypeManage)).Delete("/holiday/{holidayID}", handler.DeleteHoliday)
+
+				admin.With(can(utilities.PermissionLocationManage)).Post("/gram-panchayat-information", handler.AddGramPanchayatInformation)
+				admin.With(can(utilities.PermissionLocationView)).Get("/gram-panchayat-information", handler.GetGramPanchayatInformation)
+
+				admin.With(can(utilities.PermissionDashboardView)).Get("/district-post", handler.GetDistrictPostInfo)
+				admin.With(can(utilities.PermissionDashboardView)).Get("/total-deaths", handler.GetTotalDeaths)
+
+				admin.With(can(utilities.PermissionDashboardView)).Get("/deaths", handler.GetDeathDetailsAdmin)
+				admin.With(can(utilities.PermissionDeathEdit)).Put("/death/{deathID}", handler.EditDeathAdmin)
+				admin.With(can(utilities.PermissionDeathArchive)).Put("/death/{deathID}/archive", handler.ArchiveDeath)
+				admin.With(can(utilities.PermissionDeathArchive)).Put("/death/{deathID}/restore", handler.RestoreDeath)
+				admin.With(can(utilities.PermissionDeathArchive)).Get("/archived-deaths", handler.GetArchivedDeaths)
+				admin.With(middleware.RequireAnyPermission(utilities.PermissionDashboardView, utilities.PermissionDeathReview)).Get("/death/{deathID}/versions", handler.GetDeathVersionsAdmin)
+				admin.With(can(utilities.PermissionDashboardView)).Get("/death/{deathID}/eligibility", handler.GetDeathEligibility)
+				admin.With(can(utilities.PermissionDashboardView)).Get("/task/{taskID}/history", handler.GetTaskStatusHistory)
+				admin.With(can(utilities.PermissionTaskAssign)).Put("/task/{taskID}/assign", handler.AssignTask)
+				admin.With(can(utilities.PermissionDashboardView)).Get("/task/{taskID}/ledger", handler.GetTaskLedger)
+				admin.With(can(utilities.PermissionPayoutManage)).Post("/task/{taskID}/sanction", handler.AddSanction)
+				admin.With(can(utilities.PermissionPayoutManage)).Put("/sanction/{sanctionID}", handler.EditSanction)
+				admin.With(can(utilities.PermissionPayoutManage)).Put("/sanction/{sanctionID}/archive", handler.ArchiveSanction)
+				admin.With(can(utilities.PermissionPayoutManage)).Post("/sanction/{sanctionID}/payout", handler.AddPayout)
+				admin.With(can(utilities.PermissionDashboardView)).Get("/payout-totals", handler.GetPayoutTotals)
+
+				admin.With(can(utilities.PermissionLocationManage)).Put("/edit-gram-panchayat", handler.EditGramPanchayat)
+				admin.With(can(utilities.PermissionLocationManage)).Put("/edit-tehsil", handler.EditTehsil)
+				admin.With(can(utilities.PermissionLocationManage)).Post("/block", handler.AddBlock)
+				admin.With(can(utilities.PermissionLocationManage)).Put("/block", handler.EditBlock)
+				admin.With(can(utilities.PermissionLocationView)).Get("/block", handler.GetBlock)
+				admin.With(can(utilities.PermissionLocationManage)).Post("/block-officer", handler.AddBlockOfficer)
+				admin.With(can(utilities.PermissionDeathCodeManage)).Post("/death-code", handler.AddDeathCode)
+				admin.With(can(utilities.PermissionDeathCodeManage)).Put("/death-code/{codeID}", handler.EditDeathCode)
+				admin.With(can(utilities.PermissionDeathCodeManage)).Delete("/death-code/{codeID}", handler.DeleteDeathCode)
+				admin.With(can(utilities.PermissionDeathReview)).Get("/death-review", handler.FetchDeathReview)
+				admin.With(can(utilities.PermissionDeathReview)).Put("/death-review", handler.ReviewDeathDetails)
+
+				admin.With(can(utilities.PermissionLocationManage)).Post("/gaon", handler.AddGaon)
+				admin.With(can(utilities.PermissionLocationView)).Get("/gaon", handler.GetGaon)
+				admin.With(can(utilities.PermissionLocationManage)).Put("/gaon", handler.EditGaon)
+
+				admin.With(can(utilities.PermissionSessionRevoke)).Delete("/user/{userID}/sessions", handler.RevokeUserSessions)
+				admin.With(can(utilities.PermissionPasswordReset)).Post("/user/{userID}/password-reset", handler.IssuePasswordReset)
+
+				admin.With(can(utilities.PermissionAuditView)).Get("/audit-log", handler.GetAuditLogs)
+				admin.With(can(utilities.PermissionAuditView)).Get("/audit-log/verify", handler.VerifyAuditLog)
 			})
 		})
 	})
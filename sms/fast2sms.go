@@ -0,0 +1,69 @@
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const fast2SMSURL = "https://www.fast2sms.com/dev/bulkV2"
+
+type Fast2SMS struct {
+	authorizationKey string
+	client           *http.Client
+}
+
+type fast2SMSResponse struct {
+	Return  bool            `json:"return"`
+	Message json.RawMessage `json:"message"`
+}
+
+func NewFast2SMS(authorizationKey string) (*Fast2SMS, error) {
+	if authorizationKey == "" {
+		return nil, errors.New("fast2sms: SMS_AUTHORIZATION_KEY is not set")
+	}
+	return &Fast2SMS{
+		authorizationKey: authorizationKey,
+		client:           &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (f *Fast2SMS) SendOTP(phone, otp string) error {
+	query := url.Values{}
+	query.Set("sender_id", "TXTIND")
+	query.Set("message", otpMessage(otp))
+	query.Set("route", "v3")
+	query.Set("numbers", phone)
+
+	req, err := http.NewRequest(http.MethodPost, fast2SMSURL+"?"+query.Encode(), nil)
+	if err != nil {
+		logrus.Printf("Fast2SMS: unable to create request. %v", err)
+		return err
+	}
+	req.Header.Add("Authorization", f.authorizationKey)
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		logrus.Printf("Fast2SMS: unable to get response. %v", err)
+		return fmt.Errorf("%w: %v", ErrDeliveryFailed, err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		logrus.Printf("Fast2SMS: unable to read response body. %v", err)
+		return err
+	}
+
+	var response fast2SMSResponse
+	if err := json.Unmarshal(body, &response); err != nil || res.StatusCode != http.StatusOK || !response.Return {
+		logrus.Printf("Fast2SMS: delivery failed, status:%d body:%s", res.StatusCode, string(body))
+		return fmt.Errorf("%w: fast2sms responded with status %d", ErrDeliveryFailed, res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sms
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+// Console logs the otp instead of sending it, meant for local and staging environments
+type Console struct{}
+
+func NewConsole() *Console {
+	return &Console{}
+}
+
+func (c *Console) SendOTP(phone, otp string) error {
+	logrus.Printf("Console SMS: otp for %s is %s", phone, otp)
+	return nil
+}
+
+// File appends every otp to an outbox file so test environments can read it back
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFile(path string) (*File, error) {
+	if path == "" {
+		return nil, errors.New("file sms: SMS_OUTBOX_FILE is not set")
+	}
+	return &File{path: path}, nil
+}
+
+func (f *File) SendOTP(phone, otp string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		logrus.Printf("File SMS: unable to open outbox. %v", err)
+		return fmt.Errorf("%w: %v", ErrDeliveryFailed, err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), phone, otp)
+	if err != nil {
+		logrus.Printf("File SMS: unable to write outbox. %v", err)
+		return fmt.Errorf("%w: %v", ErrDeliveryFailed, err)
+	}
+	return nil
+}
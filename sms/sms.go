@@ -0,0 +1,43 @@
+package sms
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	ProviderFast2SMS = "fast2sms"
+	ProviderTwilio   = "twilio"
+	ProviderConsole  = "console"
+	ProviderFile     = "file"
+)
+
+// ErrDeliveryFailed is returned (wrapped) when the provider was reachable but refused or failed to deliver the message
+var ErrDeliveryFailed = errors.New("sms delivery failed")
+
+// OTPSender delivers a one time password to a phone number
+type OTPSender interface {
+	SendOTP(phone, otp string) error
+}
+
+// NewFromEnv returns the OTPSender selected by the SMS_PROVIDER environment variable, defaults to fast2sms
+func NewFromEnv() (OTPSender, error) {
+	provider := strings.ToLower(os.Getenv("SMS_PROVIDER"))
+	switch provider {
+	case "", ProviderFast2SMS:
+		return NewFast2SMS(os.Getenv("SMS_AUTHORIZATION_KEY"))
+	case ProviderTwilio:
+		return NewTwilio(os.Getenv("TWILIO_ACCOUNT_SID"), os.Getenv("TWILIO_AUTH_TOKEN"), os.Getenv("TWILIO_FROM_NUMBER"))
+	case ProviderConsole:
+		return NewConsole(), nil
+	case ProviderFile:
+		return NewFile(os.Getenv("SMS_OUTBOX_FILE"))
+	}
+	return nil, fmt.Errorf("unknown sms provider %q", provider)
+}
+
+func otpMessage(otp string) string {
+	return fmt.Sprintf("This is your OTP : %s", otp)
+}
@@ -0,0 +1,86 @@
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const twilioMessagesURL = "https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json"
+
+type Twilio struct {
+	accountSid string
+	authToken  string
+	fromNumber string
+	client     *http.Client
+}
+
+type twilioErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func NewTwilio(accountSid, authToken, fromNumber string) (*Twilio, error) {
+	if accountSid == "" || authToken == "" || fromNumber == "" {
+		return nil, errors.New("twilio: TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER must be set")
+	}
+	return &Twilio{
+		accountSid: accountSid,
+		authToken:  authToken,
+		fromNumber: fromNumber,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (t *Twilio) SendOTP(phone, otp string) error {
+	form := url.Values{}
+	form.Set("From", t.fromNumber)
+	form.Set("To", toE164(phone))
+	form.Set("Body", otpMessage(otp))
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(twilioMessagesURL, t.accountSid), strings.NewReader(form.Encode()))
+	if err != nil {
+		logrus.Printf("Twilio: unable to create request. %v", err)
+		return err
+	}
+	req.SetBasicAuth(t.accountSid, t.authToken)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		logrus.Printf("Twilio: unable to get response. %v", err)
+		return fmt.Errorf("%w: %v", ErrDeliveryFailed, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		logrus.Printf("Twilio: unable to read response body. %v", err)
+		return err
+	}
+	var twilioErr twilioErrorResponse
+	_ = json.Unmarshal(body, &twilioErr)
+	logrus.Printf("Twilio: delivery failed, status:%d code:%d message:%s", res.StatusCode, twilioErr.Code, twilioErr.Message)
+	return fmt.Errorf("%w: twilio responded with status %d", ErrDeliveryFailed, res.StatusCode)
+}
+
+// toE164 prefixes bare 10 digit indian numbers with the country code, twilio rejects them otherwise
+func toE164(phone string) string {
+	if strings.HasPrefix(phone, "+") {
+		return phone
+	}
+	if len(phone) == 10 {
+		return "+91" + phone
+	}
+	return "+" + phone
+}
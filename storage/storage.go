@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	ProviderLocal = "local"
+)
+
+// ErrNotFound is returned when no file is stored under the key
+var ErrNotFound = errors.New("file not found")
+
+// FileStore keeps uploaded files under keys chosen by the caller
+type FileStore interface {
+	Save(key string, content io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// NewFromEnv returns the FileStore selected by the STORAGE_PROVIDER environment variable, defaults to the local filesystem
+func NewFromEnv() (FileStore, error) {
+	provider := strings.ToLower(os.Getenv("STORAGE_PROVIDER"))
+	switch provider {
+	case "", ProviderLocal:
+		return NewLocal(os.Getenv("STORAGE_LOCAL_DIR"))
+	}
+	return nil, fmt.Errorf("unknown storage provider %q", provider)
+}
@@ -0,0 +1,70 @@
+package utilities
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// honorifics are left out when names are compared, "Late Shri Ram Lal" is the same person as "Ram Lal"
+var honorifics = map[string]bool{
+	"late": true, "shri": true, "sri": true, "smt": true, "km": true, "mr": true, "mrs": true, "ms": true, "dr": true,
+}
+
+// normaliseName keeps the words of a name in lower case, without honorifics, and sorts them, so "Kumar, Ram" and
+// "ram  kumar" read the same
+func normaliseName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	kept := make([]string, 0, len(words))
+	for _, word := range words {
+		if !honorifics[word] {
+			kept = append(kept, word)
+		}
+	}
+	sort.Strings(kept)
+	return strings.Join(kept, " ")
+}
+
+// NameSimilarity compares two names as people type them, 1 is the same name and 0 has nothing in common
+func NameSimilarity(a, b string) float64 {
+	first, second := []rune(normaliseName(a)), []rune(normaliseName(b))
+	longest := len(first)
+	if len(second) > longest {
+		longest = len(second)
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(first, second))/float64(longest)
+}
+
+// levenshtein counts the insertions, deletions and substitutions turning a into b
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j] + 1
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
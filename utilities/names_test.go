@@ -0,0 +1,81 @@
+package utilities
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormaliseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Ram Kumar", "kumar ram"},
+		{"Kumar, Ram", "kumar ram"},
+		{"  ram   kumar ", "kumar ram"},
+		{"Late Shri Ram Lal", "lal ram"},
+		{"Smt. Sita Devi", "devi sita"},
+		{"", ""},
+		{"Shri", ""},
+	}
+
+	for _, test := range tests {
+		if got := normaliseName(test.name); got != test.want {
+			t.Errorf("normaliseName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"ram", "", 3},
+		{"", "ram", 3},
+		{"ram", "ram", 0},
+		{"ram", "rim", 1},
+		{"ram", "rama", 1},
+		{"rama", "ram", 1},
+		{"kitten", "sitting", 3},
+		{"सीता", "सिता", 1},
+	}
+
+	for _, test := range tests {
+		if got := levenshtein([]rune(test.a), []rune(test.b)); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"same name", "Ram Kumar", "Ram Kumar", 1},
+		{"other case", "RAM KUMAR", "ram kumar", 1},
+		{"reordered", "Kumar Ram", "Ram Kumar", 1},
+		{"reordered with a comma", "Kumar, Ram", "Ram Kumar", 1},
+		{"extra whitespace", "  Ram \t Kumar  ", "Ram Kumar", 1},
+		{"honorific", "Late Shri Ram Kumar", "Ram Kumar", 1},
+		{"one letter off", "Ram Kumar", "Ram Kumer", 1 - 1.0/9},
+		{"nothing in common", "abc", "xyz", 0},
+		{"both empty", "", "", 0},
+		{"only honorifics", "Shri", "Smt", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := NameSimilarity(test.a, test.b)
+			if math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("NameSimilarity(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+			}
+			if reverse := NameSimilarity(test.b, test.a); reverse != got {
+				t.Errorf("NameSimilarity(%q, %q) = %v, the other way round %v", test.b, test.a, reverse, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package utilities
+
+import "time"
+
+// escalation levels of an overdue task, each one is a step up the chain
+const (
+	EscalationNone = iota
+	EscalationSachiv
+	EscalationSDM
+	EscalationDistrict
+)
+
+// Holidays are the days off working day slas skip, keyed by their date
+type Holidays map[string]bool
+
+func holidayKey(day time.Time) string {
+	return day.Format("2006-01-02")
+}
+
+func NewHolidays(dates []time.Time) Holidays {
+	holidays := make(Holidays, len(dates))
+	for _, date := range dates {
+		holidays[holidayKey(date)] = true
+	}
+	return holidays
+}
+
+func (h Holidays) IsHoliday(day time.Time) bool {
+	return h[holidayKey(day)]
+}
+
+// DueDate adds the sla to the start, in working days (monday to friday, except holidays) or calendar days
+func DueDate(start time.Time, slaDays int, workingDays bool, holidays Holidays) time.Time {
+	if !workingDays {
+		return start.AddDate(0, 0, slaDays)
+	}
+	due := start
+	for added := 0; added < slaDays; {
+		due = due.AddDate(0, 0, 1)
+		if due.Weekday() != time.Saturday && due.Weekday() != time.Sunday && !holidays.IsHoliday(due) {
+			added++
+		}
+	}
+	return due
+}
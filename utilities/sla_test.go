@@ -0,0 +1,62 @@
+package utilities
+
+import (
+	"testing"
+	"time"
+)
+
+func day(date string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", date+" 10:30")
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestDueDate(t *testing.T) {
+	// 2026-10-12 is a monday
+	diwali := NewHolidays([]time.Time{day("2026-10-20")})
+	tests := []struct {
+		name        string
+		start       string
+		slaDays     int
+		workingDays bool
+		holidays    Holidays
+		want        string
+	}{
+		{"calendar days", "2026-10-12", 7, false, nil, "2026-10-19"},
+		{"calendar days over a weekend", "2026-10-16", 2, false, nil, "2026-10-18"},
+		{"calendar days ignore holidays", "2026-10-19", 2, false, diwali, "2026-10-21"},
+		{"no sla days", "2026-10-12", 0, true, nil, "2026-10-12"},
+		{"working days in the week", "2026-10-12", 3, true, nil, "2026-10-15"},
+		{"working days over a weekend", "2026-10-15", 3, true, nil, "2026-10-20"},
+		{"started on a friday", "2026-10-16", 1, true, nil, "2026-10-19"},
+		{"started on a saturday", "2026-10-17", 1, true, nil, "2026-10-19"},
+		{"started on a sunday", "2026-10-18", 5, true, nil, "2026-10-23"},
+		{"working days skip a holiday", "2026-10-19", 2, true, diwali, "2026-10-22"},
+		{"working days over a weekend and a holiday", "2026-10-16", 2, true, diwali, "2026-10-21"},
+		{"holiday is the start", "2026-10-20", 1, true, diwali, "2026-10-21"},
+		{"holiday on a weekend", "2026-10-16", 1, true, NewHolidays([]time.Time{day("2026-10-17")}), "2026-10-19"},
+		{"nothing to skip", "2026-10-19", 2, true, NewHolidays(nil), "2026-10-21"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := DueDate(day(test.start), test.slaDays, test.workingDays, test.holidays)
+			if want := day(test.want); !got.Equal(want) {
+				t.Errorf("DueDate() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestIsHoliday(t *testing.T) {
+	holidays := NewHolidays([]time.Time{day("2026-01-26")})
+	// a date column comes back at midnight, the day is what matters
+	if !holidays.IsHoliday(time.Date(2026, 1, 26, 23, 59, 0, 0, time.UTC)) {
+		t.Errorf("IsHoliday(2026-01-26) = false, want true")
+	}
+	if holidays.IsHoliday(day("2026-01-27")) {
+		t.Errorf("IsHoliday(2026-01-27) = true, want false")
+	}
+}
@@ -14,6 +14,140 @@ const (
 	Sahayak            = "Sahayak"
 	SDM                = "SDM"
 	LekhPal            = "Lekhpal"
+	BlockOfficer       = "BDO"
+)
+
+// statuses of a task, see helper.taskTransitions for the allowed changes
+const (
+	TaskStatusNew        = "new"
+	TaskStatusProcessing = "processing"
+	TaskStatusCompleted  = "completed"
+)
+
+// relations of a beneficiary to the deceased, same as the beneficiary_relation enum
+var BeneficiaryRelations = []string{"spouse", "son", "daughter", "father", "mother", "brother", "sister", "other"}
+
+// kinds of the death code lists, same as the check on death_code
+const (
+	DeathCodeCause        = "cause"
+	DeathCodePlace        = "place"
+	DeathCodeCircumstance = "circumstance"
+)
+
+// DeathStatusNoTasks lists the deaths none of the task types were eligible for, next to new, processing and completed
+const DeathStatusNoTasks = "no_tasks"
+
+// outcomes of a completed task
+const (
+	TaskOutcomeCompleted     = "completed"
+	TaskOutcomeNotApplicable = "not_applicable"
+	TaskOutcomeRejected      = "rejected"
+)
+
+// permissions are granted to roles through the role_permissions table
+const (
+	PermissionDeathRegister    = "death:register"
+	PermissionDeathView        = "death:view"
+	PermissionDeathReview      = "death:review"
+	PermissionTaskUpdate       = "task:update"
+	PermissionTaskViewAll      = "task:view-all"
+	PermissionTaskTypeView     = "task-type:view"
+	PermissionDashboardView    = "dashboard:view"
+	PermissionLocationView     = "location:view"
+	PermissionLocationManage   = "location:manage"
+	PermissionRoleManage       = "role:manage"
+	PermissionPermissionManage = "permission:manage"
+	PermissionSessionRevoke    = "session:revoke"
+	PermissionScopeDistrict    = "scope:district"
+	PermissionPasswordLogin    = "auth:password-login"
+	PermissionPasswordReset    = "auth:password-reset"
+	PermissionAuditView        = "audit:view"
+	PermissionTaskReopen       = "task:reopen"
+	PermissionTaskAssign       = "task:assign"
+	PermissionTaskTypeManage   = "task-type:manage"
+	PermissionAttachmentDelete = "attachment:delete"
+	PermissionBeneficiaryEdit  = "beneficiary:edit"
+	PermissionPayoutManage     = "payout:manage"
+	PermissionDeathCodeManage  = "death-code:manage"
+	PermissionDeathEdit        = "death:edit"
+	PermissionDeathArchive     = "death:archive"
+)
+
+// actions recorded in the audit log
+const (
+	AuditDeathRegister       = "death.register"
+	AuditDeathReview         = "death.review"
+	AuditDeathEdit           = "death.edit"
+	AuditDeathArchive        = "death.archive"
+	AuditDeathRestore        = "death.restore"
+	AuditTaskProcessing      = "task.start-processing"
+	AuditTaskComplete        = "task.complete"
+	AuditTaskReject          = "task.reject"
+	AuditTaskNotApplicable   = "task.not-applicable"
+	AuditTaskReopen          = "task.reopen"
+	AuditTaskAssign          = "task.assign"
+	AuditTaskTypeSLASet      = "task-type.set-sla"
+	AuditTaskTypePrereqSet   = "task-type.set-prerequisites"
+	AuditTaskTypeRulesSet    = "task-type.set-eligibility-rules"
+	AuditTaskTypeMonetarySet = "task-type.set-monetary"
+	AuditAttachmentAdd       = "attachment.add"
+	AuditAttachmentDelete    = "attachment.delete"
+	AuditCommentAdd          = "comment.add"
+	AuditBeneficiaryAdd      = "beneficiary.add"
+	AuditBeneficiaryEdit     = "beneficiary.edit"
+	AuditBeneficiaryDelete   = "beneficiary.delete"
+	AuditSanctionAdd         = "payout.sanction"
+	AuditSanctionEdit        = "payout.edit-sanction"
+	AuditSanctionArchive     = "payout.archive-sanction"
+	AuditPayoutAdd           = "payout.disburse"
+	AuditDeathCodeAdd        = "death-code.add"
+	AuditDeathCodeEdit       = "death-code.edit"
+	AuditDeathCodeDelete     = "death-code.delete"
+	AuditTehsilAdd           = "tehsil.add"
+	AuditTehsilEdit          = "tehsil.edit"
+	AuditGramPanchayatAdd    = "gram-panchayat.add"
+	AuditGramPanchayatEdit   = "gram-panchayat.edit"
+	AuditGaonAdd             = "gaon.add"
+	AuditGaonEdit            = "gaon.edit"
+	AuditBlockAdd            = "block.add"
+	AuditBlockEdit           = "block.edit"
+	AuditUserAdd             = "user.add"
+	AuditUserEdit            = "user.edit"
+	AuditRoleAdd             = "role.add"
+	AuditRolePermissionsSet  = "role.set-permissions"
+	AuditSessionsRevoke      = "user.revoke-sessions"
+	AuditPasswordResetIssue  = "user.issue-password-reset"
+	AuditPasswordChange      = "user.change-password"
+	AuditPasswordReset       = "user.reset-password"
+	AuditTOTPSetup           = "user.setup-totp"
+	AuditTOTPEnable          = "user.enable-totp"
+	AuditTOTPDisable         = "user.disable-totp"
+	AuditHolidayAdd          = "holiday.add"
+	AuditHolidayDelete       = "holiday.delete"
+)
+
+// entities of the audit log, these are the tables the changed rows live in
+const (
+	EntityDeath           = "death_details"
+	EntityDeathReview     = "death_review"
+	EntityTask            = "task"
+	EntityTehsil          = "tehsil"
+	EntityGramPanchayat   = "gram_panchayat"
+	EntityGaon            = "gaon"
+	EntityBlock           = "block"
+	EntityUser            = "users"
+	EntityRole            = "roles"
+	EntityRolePermissions = "role_permissions"
+	EntityTaskType        = "task_types"
+	EntityTaskTypePrereqs = "task_type_prerequisite"
+	EntityTaskTypeRules   = "task_type_eligibility_rule"
+	EntityAttachment      = "attachment"
+	EntityComment         = "comment"
+	EntityBeneficiary     = "beneficiary"
+	EntitySanction        = "task_sanction"
+	EntityPayout          = "payout"
+	EntityDeathCode       = "death_code"
+	EntityHoliday         = "holiday"
 )
 
 func Decoder(r *http.Request, inter interface{}) error {
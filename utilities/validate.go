@@ -0,0 +1,63 @@
+package utilities
+
+import "regexp"
+
+var (
+	aadhaarPattern     = regexp.MustCompile(`^[2-9][0-9]{11}$`)
+	phonePattern       = regexp.MustCompile(`^[6-9][0-9]{9}$`)
+	ifscPattern        = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`)
+	bankAccountPattern = regexp.MustCompile(`^[0-9]{9,18}$`)
+)
+
+// verhoeff tables, aadhaar numbers carry a verhoeff check digit at the end
+var (
+	verhoeffMultiplication = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffPermutation = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+)
+
+// IsValidAadhaar checks the format and the verhoeff check digit of a 12 digit aadhaar number
+func IsValidAadhaar(aadhaar string) bool {
+	if !aadhaarPattern.MatchString(aadhaar) {
+		return false
+	}
+	check := 0
+	for i := 0; i < len(aadhaar); i++ {
+		digit := int(aadhaar[len(aadhaar)-1-i] - '0')
+		check = verhoeffMultiplication[check][verhoeffPermutation[i%8][digit]]
+	}
+	return check == 0
+}
+
+// IsValidPhone accepts 10 digit indian mobile numbers without the country code
+func IsValidPhone(phone string) bool {
+	return phonePattern.MatchString(phone)
+}
+
+// IsValidIFSC checks the bank code, the reserved zero and the branch code of an IFSC
+func IsValidIFSC(ifsc string) bool {
+	return ifscPattern.MatchString(ifsc)
+}
+
+func IsValidBankAccount(accountNumber string) bool {
+	return bankAccountPattern.MatchString(accountNumber)
+}
@@ -0,0 +1,95 @@
+package utilities
+
+import "testing"
+
+func TestIsValidAadhaar(t *testing.T) {
+	tests := []struct {
+		aadhaar string
+		want    bool
+	}{
+		{"234123412346", true},
+		{"499185340128", true},
+		{"987654321012", true},
+		{"234123412345", false},
+		{"234123412364", false},
+		{"134123412346", false},
+		{"034123412346", false},
+		{"23412341234", false},
+		{"2341234123466", false},
+		{"2341 2341 2346", false},
+		{"23412341234a", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := IsValidAadhaar(test.aadhaar); got != test.want {
+			t.Errorf("IsValidAadhaar(%q) = %v, want %v", test.aadhaar, got, test.want)
+		}
+	}
+}
+
+func TestIsValidPhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  bool
+	}{
+		{"9876543210", true},
+		{"6000000000", true},
+		{"5876543210", false},
+		{"0987654321", false},
+		{"987654321", false},
+		{"98765432100", false},
+		{"+919876543210", false},
+		{"98765 43210", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := IsValidPhone(test.phone); got != test.want {
+			t.Errorf("IsValidPhone(%q) = %v, want %v", test.phone, got, test.want)
+		}
+	}
+}
+
+func TestIsValidIFSC(t *testing.T) {
+	tests := []struct {
+		ifsc string
+		want bool
+	}{
+		{"SBIN0001234", true},
+		{"HDFC0ABC123", true},
+		{"sbin0001234", false},
+		{"SBIN1001234", false},
+		{"SBI00001234", false},
+		{"SBIN000123", false},
+		{"SBIN00012345", false},
+		{"SBIN0-01234", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := IsValidIFSC(test.ifsc); got != test.want {
+			t.Errorf("IsValidIFSC(%q) = %v, want %v", test.ifsc, got, test.want)
+		}
+	}
+}
+
+func TestIsValidBankAccount(t *testing.T) {
+	tests := []struct {
+		accountNumber string
+		want          bool
+	}{
+		{"123456789", true},
+		{"123456789012345678", true},
+		{"12345678", false},
+		{"1234567890123456789", false},
+		{"12345678901a", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := IsValidBankAccount(test.accountNumber); got != test.want {
+			t.Errorf("IsValidBankAccount(%q) = %v, want %v", test.accountNumber, got, test.want)
+		}
+	}
+}